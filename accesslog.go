@@ -0,0 +1,202 @@
+package jsonrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of redacted headers and body fields.
+const redacted = "[REDACTED]"
+
+// An AccessLogOption configures the AccessLog middleware.
+type AccessLogOption func(*accessLogConfig)
+
+// accessLogConfig is the configuration of the AccessLog middleware.
+type accessLogConfig struct {
+	headers       bool
+	redactHeaders map[string]bool
+	maxBodySize   int64
+	redactFields  map[string]bool
+	sampleRate    float64
+}
+
+// LogHeaders is an AccessLogOption which logs the request headers. The values
+// of the Authorization, Proxy-Authorization and Cookie headers, and of any
+// headers given to RedactHeaders, are redacted.
+func LogHeaders() AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.headers = true
+	}
+}
+
+// RedactHeaders is an AccessLogOption which redacts the values of the named
+// request headers when they are logged.
+func RedactHeaders(names ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		for _, name := range names {
+			c.redactHeaders[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+}
+
+// LogRequestBody is an AccessLogOption which logs JSON request bodies of at
+// most maxSize bytes. Larger bodies, and bodies which are not JSON, are not
+// logged.
+func LogRequestBody(maxSize int64) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.maxBodySize = maxSize
+	}
+}
+
+// RedactFields is an AccessLogOption which redacts the values of the object
+// members with the given names, at any depth, in logged request bodies.
+func RedactFields(names ...string) AccessLogOption {
+	return func(c *accessLogConfig) {
+		for _, name := range names {
+			c.redactFields[name] = true
+		}
+	}
+}
+
+// SampleSuccesses is an AccessLogOption which logs only the given fraction,
+// between 0 and 1, of requests with a status code below 400. Other requests
+// are always logged.
+func SampleSuccesses(rate float64) AccessLogOption {
+	return func(c *accessLogConfig) {
+		c.sampleRate = rate
+	}
+}
+
+// AccessLog returns a Middleware which logs each request to logger once its
+// response has been written, recording the method, route pattern, path,
+// status code, latency, response size, request ID (see RequestID) and, for
+// errors, the error code. Requests are logged at the info level, client errors
+// at the warn level and server errors at the error level.
+//
+// For the request ID to be logged, the RequestID middleware must run first.
+func AccessLog(logger *slog.Logger, opts ...AccessLogOption) Middleware {
+	c := accessLogConfig{
+		redactHeaders: map[string]bool{
+			"Authorization":       true,
+			"Proxy-Authorization": true,
+			"Cookie":              true,
+		},
+		redactFields: make(map[string]bool),
+		sampleRate:   1,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			start := time.Now()
+			var body interface{}
+			if c.maxBodySize > 0 {
+				body = c.readBody(req)
+			}
+
+			req.OnResponse(func(res ResponseInfo) {
+				if res.Status < 400 && c.sampleRate < 1 && rand.Float64() >= c.sampleRate {
+					return
+				}
+				attrs := []slog.Attr{
+					slog.String("method", req.Method()),
+					slog.String("route", req.Route()),
+					slog.String("path", req.URL().Path),
+					slog.Int("status", res.Status),
+					slog.Duration("latency", time.Since(start)),
+					slog.Int64("bytes", res.Bytes),
+				}
+				if id := req.ID(); id != "" {
+					attrs = append(attrs, slog.String("request_id", id))
+				}
+				if res.ErrorCode != "" {
+					attrs = append(attrs, slog.String("error_code", res.ErrorCode))
+				}
+				if c.headers {
+					attrs = append(attrs, slog.Any("headers", c.logHeaders(req.req.Header)))
+				}
+				if body != nil {
+					attrs = append(attrs, slog.Any("body", body))
+				}
+
+				level := slog.LevelInfo
+				switch {
+				case res.Status >= 500:
+					level = slog.LevelError
+				case res.Status >= 400:
+					level = slog.LevelWarn
+				}
+				logger.LogAttrs(ctx, level, "request", attrs...)
+			})
+
+			return next(ctx, req)
+		}
+	}
+}
+
+// logHeaders returns the headers to be logged, with redacted values.
+func (c *accessLogConfig) logHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for name, values := range header {
+		if c.redactHeaders[name] {
+			headers[name] = redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// readBody returns the JSON request body to be logged with redacted fields,
+// or nil if it is not logged. The body is restored so that the endpoint can
+// read it.
+func (c *accessLogConfig) readBody(req *Request) interface{} {
+	if !hasBody(req.req) || !isJSONMediaType(mediaType(req.Header("Content-Type"))) {
+		return nil
+	}
+	data, err := io.ReadAll(io.LimitReader(req.req.Body, c.maxBodySize+1))
+	req.req.Body = readCloser{io.MultiReader(bytes.NewReader(data), req.req.Body), req.req.Body}
+	if err != nil || int64(len(data)) > c.maxBodySize {
+		return nil
+	}
+
+	var body interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil
+	}
+	return c.redact(body)
+}
+
+// redact replaces the values of redacted fields in the JSON value v.
+func (c *accessLogConfig) redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, val := range v {
+			if c.redactFields[k] {
+				v[k] = redacted
+			} else {
+				v[k] = c.redact(val)
+			}
+		}
+	case []interface{}:
+		for i, val := range v {
+			v[i] = c.redact(val)
+		}
+	}
+	return v
+}
+
+// readCloser reads from a reader and closes a closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
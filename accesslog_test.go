@@ -0,0 +1,124 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	entries := func() []m {
+		var entries []m
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var e m
+			assert.Must(t, dec.Decode(&e))
+			delete(e, "time")
+			delete(e, "latency")
+			entries = append(entries, e)
+		}
+		buf.Reset()
+		return entries
+	}
+
+	r := jsonrest.NewRouter()
+	r.Use(jsonrest.RequestID(), jsonrest.AccessLog(logger,
+		jsonrest.LogHeaders(),
+		jsonrest.RedactHeaders("X-Api-Key"),
+		jsonrest.LogRequestBody(1024),
+		jsonrest.RedactFields("password"),
+	))
+	r.Post("/users/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := req.BindBody(&body); err != nil {
+			return nil, err
+		}
+		return jsonrest.M{"name": body.Name}, nil
+	})
+
+	body := `{"name": "alice", "credentials": [{"password": "hunter2"}]}`
+	w := do(r, http.MethodPost, "/users/1", strings.NewReader(body), "application/json", map[string]string{
+		"X-Request-ID":  "req-1",
+		"Authorization": "Bearer token",
+		"X-Api-Key":     "key",
+	})
+	assert.JSONEqual(t, w.Body.String(), m{"name": "alice"})
+	assert.JSONEqual(t, entries(), []m{{
+		"level":      "INFO",
+		"msg":        "request",
+		"method":     "POST",
+		"route":      "/users/:id",
+		"path":       "/users/1",
+		"status":     200,
+		"bytes":      w.Body.Len(),
+		"request_id": "req-1",
+		"headers": m{
+			"Authorization": "[REDACTED]",
+			"Content-Type":  "application/json",
+			"X-Api-Key":     "[REDACTED]",
+			"X-Request-Id":  "req-1",
+		},
+		"body": m{"name": "alice", "credentials": []m{{"password": "[REDACTED]"}}},
+	}})
+
+	r = jsonrest.NewRouter()
+	r.Use(jsonrest.AccessLog(logger, jsonrest.SampleSuccesses(0)))
+	r.Get("/ok", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+	r.Get("/missing", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("no such thing")
+	})
+	r.Get("/internal", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("secret failure")
+	})
+
+	do(r, http.MethodGet, "/ok", nil, "application/json", nil)
+	assert.Equal(t, len(entries()), 0)
+
+	do(r, http.MethodGet, "/missing", nil, "application/json", nil)
+	do(r, http.MethodGet, "/internal", nil, "application/json", nil)
+	got := entries()
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0]["level"], "WARN")
+	assert.Equal(t, got[0]["error_code"], "not_found")
+	assert.Equal(t, got[1]["level"], "ERROR")
+	assert.Equal(t, got[1]["error_code"], "unknown_error")
+	assert.Equal(t, got[1]["status"], 500.0)
+
+	r = jsonrest.NewRouter(jsonrest.WithErrorHandler(func(ctx context.Context, req *jsonrest.Request, ev jsonrest.ErrorEvent) error {
+		if ev.Err != nil {
+			return jsonrest.Error(http.StatusServiceUnavailable, "unavailable", "try again later")
+		}
+		return nil
+	}))
+	r.Use(jsonrest.AccessLog(logger))
+	r.Get("/replaced", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("secret failure")
+	})
+	r.Get("/panic", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+
+	do(r, http.MethodGet, "/replaced", nil, "application/json", nil)
+	do(r, http.MethodGet, "/panic", nil, "application/json", nil)
+	got = entries()
+	assert.Equal(t, len(got), 2)
+	assert.Equal(t, got[0]["error_code"], "unavailable")
+	assert.Equal(t, got[0]["status"], 503.0)
+	assert.Equal(t, got[1]["error_code"], "unknown_error")
+	assert.Equal(t, got[1]["status"], 500.0)
+}
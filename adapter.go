@@ -0,0 +1,119 @@
+package jsonrest
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// UseHTTP registers standard net/http middleware to be used for all routes of
+// the router and its groups. It runs before any Middleware registered with
+// Use, and before the request is decoded, so it may reject requests or add
+// values to the request context, which are then visible to endpoints through
+// their ctx argument and Request.Raw.
+//
+// As with Use, middleware registered with a router runs outside that of its
+// groups, and is applied to routes registered before it.
+func (r *Router) UseHTTP(ms ...func(http.Handler) http.Handler) {
+	r.httpMiddleware = append(r.httpMiddleware, ms...)
+	atomic.AddUint32(&r.root().middlewareVersion, 1)
+}
+
+// HTTPMiddleware adapts the jsonrest middleware m to standard net/http
+// middleware, for use with any http.Handler. The next handler is called when m
+// calls the next endpoint, with the context passed to it. Errors returned by m
+// are rendered as by a Router created with opts. Requests are not rejected by
+// content negotiation, since the next handler writes the response, and errors
+// are rendered as JSON if no codec matches the Accept header.
+//
+// The http.ResponseWriter passed to the next handler is an http.Flusher, and
+// its Unwrap method returns the original writer, so that the handler may use
+// an http.ResponseController.
+func HTTPMiddleware(m Middleware, opts ...Option) func(http.Handler) http.Handler {
+	router := NewRouter(opts...)
+	return func(next http.Handler) http.Handler {
+		endpoint := m(func(ctx context.Context, req *Request) (interface{}, error) {
+			next.ServeHTTP(req.responseWriter, req.req.WithContext(ctx))
+			return responseWritten{}, nil
+		})
+		handle := endpointToHandler(endpoint, "", router)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			handle(w, req, nil)
+		})
+	}
+}
+
+// responseWritten is returned by an endpoint which has already written the
+// response itself.
+type responseWritten struct{}
+
+// paramsKey is the context key of the URL parameters of requests passed to
+// net/http middleware and gzip handlers.
+type paramsKey struct{}
+
+// applyHTTPMiddleware applies the net/http middleware of the router and all
+// parent routers to handle. As with applyMiddleware, the chain is composed on
+// first use, and recomposed only if middleware has since been added.
+func applyHTTPMiddleware(handle httprouter.Handle, r *Router) httprouter.Handle {
+	c := &httpMiddlewareChain{handle: handle, router: r, root: r.root()}
+	return c.serve
+}
+
+// httpMiddlewareChain is a handle composed with the net/http middleware of a
+// router and all its parents.
+type httpMiddlewareChain struct {
+	handle httprouter.Handle
+	router *Router
+	root   *Router
+
+	// compiled holds the *compiledHTTPChain for the latest middleware
+	// version.
+	compiled atomic.Value
+}
+
+// compiledHTTPChain is a net/http middleware chain composed at a middleware
+// version. The handler is nil if there is no middleware.
+type compiledHTTPChain struct {
+	version uint32
+	handler http.Handler
+}
+
+func (c *httpMiddlewareChain) serve(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	version := atomic.LoadUint32(&c.root.middlewareVersion)
+	compiled, _ := c.compiled.Load().(*compiledHTTPChain)
+	if compiled == nil || compiled.version != version {
+		compiled = &compiledHTTPChain{version: version, handler: c.compose()}
+		c.compiled.Store(compiled)
+	}
+	if compiled.handler == nil {
+		c.handle(w, req, params)
+		return
+	}
+
+	// The URL parameters are passed through the context, so that the chain
+	// need not be composed for each request.
+	ctx := context.WithValue(req.Context(), paramsKey{}, params)
+	compiled.handler.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// compose applies the net/http middleware from the router and all parent
+// routers.
+func (c *httpMiddlewareChain) compose() http.Handler {
+	var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params, _ := req.Context().Value(paramsKey{}).(httprouter.Params)
+		c.handle(w, req, params)
+	})
+	empty := true
+	for r := c.router; r != nil; r = r.parent {
+		for i := len(r.httpMiddleware) - 1; i >= 0; i-- {
+			h = r.httpMiddleware[i](h)
+			empty = false
+		}
+	}
+	if empty {
+		return nil
+	}
+	return h
+}
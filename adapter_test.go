@@ -0,0 +1,129 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type ctxKey struct{}
+
+func TestUseHTTP(t *testing.T) {
+	var calls []string
+	record := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, req)
+			})
+		}
+	}
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			user := req.Header.Get("X-User")
+			if user == "" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ctxKey{}, user)))
+		})
+	}
+
+	r := jsonrest.NewRouter()
+	r.Get("/public/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"id": req.Param("id")}, nil
+	})
+	r.UseHTTP(record("root"))
+
+	g := r.Group()
+	g.UseHTTP(auth)
+	g.Use(func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			calls = append(calls, "jsonrest")
+			return next(ctx, req)
+		}
+	})
+	g.Get("/private/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"id": req.Param("id"), "user": ctx.Value(ctxKey{})}, nil
+	})
+
+	w := do(r, http.MethodGet, "/public/1", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"id": "1"})
+	assert.Equal(t, calls, []string{"root"})
+
+	calls = nil
+	w = do(r, http.MethodGet, "/private/2", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 401)
+	assert.Equal(t, calls, []string{"root"})
+
+	calls = nil
+	w = do(r, http.MethodGet, "/private/2", nil, "application/json", map[string]string{"X-User": "alice"})
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"id": "2", "user": "alice"})
+	assert.Equal(t, calls, []string{"root", "jsonrest"})
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	auth := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			user := req.Header("X-User")
+			if user == "" {
+				return nil, jsonrest.Error(http.StatusUnauthorized, "unauthorized", "missing user")
+			}
+			return next(context.WithValue(ctx, ctxKey{}, user), req)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/hello", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("hello " + req.Context().Value(ctxKey{}).(string)))
+	})
+	h := jsonrest.HTTPMiddleware(auth)(mux)
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("Accept", "text/plain")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, w.Result().StatusCode, 401)
+	assert.JSONEqual(t, w.Body.String(), m{"error": m{"code": "unauthorized", "message": "missing user"}})
+
+	req.Header.Set("X-User", "alice")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.Equal(t, w.Body.String(), "hello alice")
+}
+
+func TestHTTPMiddlewareWriter(t *testing.T) {
+	noop := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return next
+	}
+	w := httptest.NewRecorder()
+	h := jsonrest.HTTPMiddleware(noop)(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, ok := rw.(http.Flusher)
+		assert.True(t, ok)
+
+		inner := rw
+		for {
+			u, ok := inner.(interface{ Unwrap() http.ResponseWriter })
+			if !ok {
+				break
+			}
+			inner = u.Unwrap()
+		}
+		assert.True(t, inner == http.ResponseWriter(w))
+
+		_, _ = rw.Write([]byte("chunk"))
+		rw.(http.Flusher).Flush()
+	}))
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, w.Body.String(), "chunk")
+}
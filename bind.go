@@ -0,0 +1,215 @@
+package jsonrest
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// BindQuery populates the struct pointed to by val from the querystring.
+// Fields are bound from the querystring value named by their "query" struct
+// tag, e.g.
+//
+//	var params struct {
+//	    Limit  int           `query:"limit" default:"20"`
+//	    IDs    []int         `query:"id"`
+//	    Since  *time.Time    `query:"since"`
+//	    Within time.Duration `query:"within" default:"1h"`
+//	}
+//
+// The "default" tag provides a value to use if the key is absent. Slices are
+// bound from repeated keys, pointers are left nil if the key is absent, times
+// are parsed as RFC 3339 and durations by time.ParseDuration. Fields may also
+// implement encoding.TextUnmarshaler.
+//
+// If any value cannot be converted, an HTTP 400 Bad Request error is returned
+// listing every failure in its details.
+func (r *Request) BindQuery(val interface{}) error {
+	query := r.req.URL.Query()
+	return bindValues(val, "query", "query parameters", func(name string) []string {
+		return query[name]
+	})
+}
+
+// BindParams populates the struct pointed to by val from the URL parameters,
+// using the "param" struct tag. See BindQuery for the supported field types.
+func (r *Request) BindParams(val interface{}) error {
+	return bindValues(val, "param", "url parameters", func(name string) []string {
+		for _, p := range r.params {
+			if p.Key == name {
+				return []string{p.Value}
+			}
+		}
+		return nil
+	})
+}
+
+// BindHeaders populates the struct pointed to by val from the request headers,
+// using the "header" struct tag. See BindQuery for the supported field types.
+func (r *Request) BindHeaders(val interface{}) error {
+	return bindValues(val, "header", "headers", func(name string) []string {
+		return r.req.Header[http.CanonicalHeaderKey(name)]
+	})
+}
+
+// bindValues populates the struct pointed to by val from the values returned
+// by lookup for each field's tag.
+func bindValues(val interface{}, tag, source string, lookup func(name string) []string) error {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("jsonrest: cannot bind %s to %T, need a pointer to a struct", source, val)
+	}
+
+	var details []string
+	bindStruct(v.Elem(), tag, lookup, &details)
+	if len(details) > 0 {
+		err := BadRequest("invalid " + source)
+		err.Details = details
+		return err
+	}
+	return nil
+}
+
+// bindStruct populates the fields of the struct v, appending a message to
+// details for each value that cannot be converted.
+func bindStruct(v reflect.Value, tag string, lookup func(name string) []string, details *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := field.Tag.Lookup(tag)
+		if !ok {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				bindStruct(v.Field(i), tag, lookup, details)
+			}
+			continue
+		}
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue // unexported or skipped
+		}
+
+		values := lookup(name)
+		if len(values) == 0 {
+			def, ok := field.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			values = []string{def}
+		}
+		if err := setField(v.Field(i), values); err != nil {
+			*details = append(*details, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+}
+
+// setField converts values to the type of field and stores the result.
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Ptr {
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	}
+
+	if field.Kind() == reflect.Slice && !isTextUnmarshaler(field) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+// setValue converts s to the type of v and stores the result.
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		elem := reflect.New(v.Type().Elem())
+		if err := setValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	switch v.Type() {
+	case typeTimeTime:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case typeTimeDuration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if isTextUnmarshaler(v) {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid value %q", s)
+		}
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return conversionError(v.Type(), s)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// conversionError returns a "safe" error message indicating that s cannot be
+// converted to type t.
+func conversionError(t reflect.Type, s string) error {
+	if name := jsonType(t); name != "" {
+		return fmt.Errorf("cannot parse %q as %s", s, name)
+	}
+	return fmt.Errorf("invalid value %q", s)
+}
+
+// isTextUnmarshaler reports whether a pointer to v implements
+// encoding.TextUnmarshaler.
+func isTextUnmarshaler(v reflect.Value) bool {
+	return v.CanAddr() && reflect.PtrTo(v.Type()).Implements(typeTextUnmarshaler)
+}
+
+var typeTextUnmarshaler = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
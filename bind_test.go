@@ -0,0 +1,77 @@
+package jsonrest_test
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+	"github.com/julienschmidt/httprouter"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestBindQuery(t *testing.T) {
+	type params struct {
+		Limit  int           `query:"limit" default:"20"`
+		Offset *int          `query:"offset"`
+		IDs    []int64       `query:"id"`
+		Active bool          `query:"active"`
+		Since  time.Time     `query:"since"`
+		Within time.Duration `query:"within" default:"1h"`
+		Name   string        `query:"name"`
+		Ignore string
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		req := jsonrest.NewTestRequest(nil, httptest.NewRequest("GET", "/?id=1&id=2&active=true&since=2020-01-02T03:04:05Z&name=bob&offset=5", nil), "/")
+		var got params
+		assert.Must(t, req.BindQuery(&got))
+
+		offset := 5
+		assert.Equal(t, got, params{
+			Limit:  20,
+			Offset: &offset,
+			IDs:    []int64{1, 2},
+			Active: true,
+			Since:  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Within: time.Hour,
+			Name:   "bob",
+		})
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		req := jsonrest.NewTestRequest(nil, httptest.NewRequest("GET", "/?limit=abc&id=1&id=x&within=soon", nil), "/")
+		var got params
+		err := req.BindQuery(&got)
+		httpErr, ok := err.(*jsonrest.HTTPError)
+		assert.True(t, ok)
+		assert.Equal(t, httpErr.Status, 400)
+		assert.Equal(t, httpErr.Message, "invalid query parameters")
+		assert.Equal(t, httpErr.Details, []string{
+			`limit: cannot parse "abc" as integer`,
+			`id: cannot parse "x" as integer`,
+			`within: cannot parse "soon" as duration`,
+		})
+	})
+}
+
+func TestBindParamsAndHeaders(t *testing.T) {
+	var got struct {
+		ID      int      `param:"id"`
+		TraceID string   `header:"x-trace-id"`
+		Accept  []string `header:"Accept"`
+	}
+
+	r := httptest.NewRequest("GET", "/users/12", nil)
+	r.Header.Set("X-Trace-ID", "abc")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Add("Accept", "text/plain")
+	req := jsonrest.NewTestRequest(httprouter.Params{{Key: "id", Value: "12"}}, r, "/users/:id")
+
+	assert.Must(t, req.BindParams(&got))
+	assert.Must(t, req.BindHeaders(&got))
+	assert.Equal(t, got.ID, 12)
+	assert.Equal(t, got.TraceID, "abc")
+	assert.Equal(t, got.Accept, []string{"application/json", "text/plain"})
+}
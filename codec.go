@@ -0,0 +1,275 @@
+package jsonrest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A Codec encodes response bodies and decodes request bodies of a particular
+// media type. Codecs other than JSON, such as MessagePack, CBOR or YAML, may
+// be registered with WithCodec.
+type Codec interface {
+	// ContentType returns the Content-Type header value of encoded bodies,
+	// e.g. "application/json; charset=utf-8".
+	ContentType() string
+
+	// Encode writes the encoding of v to w.
+	Encode(w io.Writer, v interface{}) error
+
+	// Decode reads the next encoded value from r and stores it in v.
+	Decode(r io.Reader, v interface{}) error
+}
+
+// JSONCodec is a Codec for application/json using encoding/json. It is always
+// registered, and is used when the client does not express a preference.
+type JSONCodec struct {
+	// Indent is the indentation applied to encoded values. If empty, values
+	// are encoded without indentation.
+	Indent string
+}
+
+// ContentType implements the Codec interface.
+func (JSONCodec) ContentType() string {
+	return "application/json; charset=utf-8"
+}
+
+// Encode implements the Codec interface.
+func (c JSONCodec) Encode(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	if c.Indent != "" {
+		enc.SetIndent("", c.Indent)
+	}
+	return enc.Encode(v)
+}
+
+// Decode implements the Codec interface.
+func (JSONCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+// XMLCodec is a Codec for application/xml using encoding/xml.
+type XMLCodec struct{}
+
+// ContentType implements the Codec interface.
+func (XMLCodec) ContentType() string {
+	return "application/xml; charset=utf-8"
+}
+
+// Encode implements the Codec interface.
+func (XMLCodec) Encode(w io.Writer, v interface{}) error {
+	return xml.NewEncoder(w).Encode(v)
+}
+
+// Decode implements the Codec interface.
+func (XMLCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+// MarshalXML implements the xml.Marshaler interface, so that M values can be
+// encoded by XMLCodec. Each entry is encoded as an element named after its
+// key, in key order, within the start element, which is <M> unless the M is
+// a field or the value of another entry.
+func (m M) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		v := m[k]
+		if nested, ok := v.(map[string]interface{}); ok {
+			v = M(nested)
+		}
+		if err := e.EncodeElement(v, xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// errNotAcceptable is returned when none of the media types accepted by the
+// client are supported.
+var errNotAcceptable = Error(http.StatusNotAcceptable, "not_acceptable", "none of the accepted media types are supported")
+
+// jsonCodec returns the router's JSON codec.
+func (r *Router) jsonCodec() Codec {
+	if r.disableJSONIndent {
+		return JSONCodec{}
+	}
+	return JSONCodec{Indent: "  "}
+}
+
+// negotiateCodec returns the codec best matching the given Accept header. If
+// the header is empty, the JSON codec is returned. Responses vary by Accept
+// header if the router has codecs other than JSON, which must be indicated
+// with the Vary header.
+func (r *Router) negotiateCodec(accept string) (Codec, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return r.jsonCodec(), true
+	}
+
+	ranges := parseAccept(accept)
+	var (
+		best  Codec
+		bestQ float64
+	)
+	consider := func(c Codec) {
+		// The quality of a media type is given by the most specific range
+		// matching it.
+		mt := mediaType(c.ContentType())
+		q, specificity := 0.0, -1
+		for _, ar := range ranges {
+			if s := rangeSpecificity(ar.mediaType); s > specificity && mediaTypeMatches(ar.mediaType, mt) {
+				q, specificity = ar.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = c, q
+		}
+	}
+	consider(r.jsonCodec())
+	for _, c := range r.codecs {
+		consider(c)
+	}
+	return best, best != nil
+}
+
+// requestCodec returns the codec registered for the given Content-Type
+// header. If the header is empty, the JSON codec is returned.
+func (r *Router) requestCodec(contentType string) (Codec, bool) {
+	mt := mediaType(contentType)
+	if mt == "" || isJSONMediaType(mt) {
+		return r.jsonCodec(), true
+	}
+	for _, c := range r.codecs {
+		if mediaType(c.ContentType()) == mt {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// checkContentType returns an HTTP 415 Unsupported Media Type error if the
+// Content-Type of a request body is not accepted by the router. Unless
+// configured WithContentTypes, the router accepts JSON, including media types
+// with a +json suffix, the media types of its codecs, and forms, which are
+// read with Request.FormFile and Request.Raw. An empty Content-Type is
+// accepted as JSON.
+func (r *Router) checkContentType(contentType string) error {
+	if contentType == "" {
+		return nil
+	}
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return unsupportedMediaType(contentType)
+	}
+
+	if r.contentTypes != nil {
+		for _, pattern := range r.contentTypes {
+			if mediaTypeMatches(mediaType(pattern), mt) {
+				return nil
+			}
+		}
+		return unsupportedMediaType(contentType)
+	}
+
+	if isJSONMediaType(mt) {
+		// JSON must be encoded as UTF-8 (RFC 8259).
+		if charset, ok := params["charset"]; ok && !strings.EqualFold(charset, "utf-8") {
+			return Error(http.StatusUnsupportedMediaType, "unsupported_media_type", fmt.Sprintf("unsupported charset %q", charset))
+		}
+		return nil
+	}
+	if mt == "multipart/form-data" || mt == "application/x-www-form-urlencoded" {
+		return nil
+	}
+	for _, c := range r.codecs {
+		if mediaType(c.ContentType()) == mt {
+			return nil
+		}
+	}
+	return unsupportedMediaType(contentType)
+}
+
+// hasBody reports whether the request has a body.
+func hasBody(req *http.Request) bool {
+	return req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0
+}
+
+// isJSONMediaType reports whether mt is application/json, or a media type
+// with a +json structured syntax suffix such as application/problem+json.
+func isJSONMediaType(mt string) bool {
+	return mt == "application/json" || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
+
+// acceptRange is a media range from an Accept header.
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses the media ranges of an Accept header.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		ar := acceptRange{mediaType: mediaType(params[0]), q: 1}
+		if ar.mediaType == "" {
+			continue
+		}
+		for _, p := range params[1:] {
+			kv := strings.SplitN(strings.TrimSpace(p), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "q") {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					ar.q = q
+				}
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+	return ranges
+}
+
+// rangeSpecificity ranks a media range by how specific it is.
+func rangeSpecificity(mediaRange string) int {
+	switch {
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*"):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// mediaType returns the lowercased media type of a Content-Type or Accept
+// value, without parameters.
+func mediaType(s string) string {
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// mediaTypeMatches reports whether the media type mt matches pattern, which
+// may be a media range such as "*/*" or "application/*".
+func mediaTypeMatches(pattern, mt string) bool {
+	if pattern == mt || pattern == "*/*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(mt, pattern[:len(pattern)-1])
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package jsonrest
+
+import (
+	"testing"
+)
+
+func TestNegotiateCodec(t *testing.T) {
+	r := &Router{codecs: []Codec{XMLCodec{}}}
+	tests := []struct {
+		accept string
+		want   string // empty if not acceptable
+	}{
+		{"", "application/json; charset=utf-8"},
+		{"*/*", "application/json; charset=utf-8"},
+		{"application/xml", "application/xml; charset=utf-8"},
+		{"application/json;q=0.5, application/xml", "application/xml; charset=utf-8"},
+		{"application/*;q=0.5, application/xml;q=0.9", "application/xml; charset=utf-8"},
+		{"application/json;q=0, */*", "application/xml; charset=utf-8"},
+		{"text/csv", ""},
+		{"text/csv, */*;q=0.1", "application/json; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		var got string
+		if c, ok := r.negotiateCodec(tt.accept); ok {
+			got = c.ContentType()
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package jsonrest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// CORSPolicy configures Cross-Origin Resource Sharing for the routes of a
+// router.
+type CORSPolicy struct {
+	// AllowedOrigins are the origins which may make cross-origin requests.
+	// An origin may be given exactly, e.g. "https://example.com", with a
+	// wildcard subdomain, e.g. "https://*.example.com", or as "*" to allow
+	// any origin. Origins are matched case-insensitively.
+	AllowedOrigins []string
+
+	// AllowOrigin, if set, is called for origins not in AllowedOrigins, and
+	// allows the origin if it returns true.
+	AllowOrigin func(origin string) bool
+
+	// AllowedMethods are the methods allowed in cross-origin requests. If it
+	// is empty, the methods of the routes matching the request path are
+	// allowed.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers allowed in cross-origin
+	// requests. If it is empty or contains "*", any requested headers are
+	// allowed.
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers which browsers may expose to
+	// scripts.
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests to include credentials such as
+	// cookies. It may not be combined with the "*" origin.
+	AllowCredentials bool
+
+	// MaxAge is how long the result of a preflight request may be cached. If
+	// it is zero, the browser default is used.
+	MaxAge time.Duration
+}
+
+// WithCORS is an Option which enables CORS for the routes of the router and its
+// groups. Preflight requests to those routes are answered automatically using
+// the policy of the route matching the requested method, without calling any
+// endpoint or middleware.
+//
+// It panics if the policy allows credentials from any origin with "*", since
+// browsers reject credentialed responses for the "*" origin, and reflecting
+// each origin instead would let any site make requests with the user's
+// credentials.
+func WithCORS(policy CORSPolicy) Option {
+	if policy.AllowCredentials && containsString(policy.AllowedOrigins, "*") {
+		panic(`jsonrest: CORS policy cannot allow credentials for the "*" origin`)
+	}
+	return routerOption("WithCORS", func(r *Router) {
+		r.cors = &policy
+	})
+}
+
+// allowsOrigin reports whether origin may make cross-origin requests.
+func (p *CORSPolicy) allowsOrigin(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.IndexByte(allowed, '*'); i >= 0 {
+			prefix, suffix := strings.ToLower(allowed[:i]), strings.ToLower(allowed[i+1:])
+			o := strings.ToLower(origin)
+			if len(o) > len(prefix)+len(suffix) &&
+				strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix) {
+				return true
+			}
+		}
+	}
+	return p.AllowOrigin != nil && p.AllowOrigin(origin)
+}
+
+// allowOriginHeader returns the value of the Access-Control-Allow-Origin
+// header for an allowed origin. Policies allowing any origin never reflect
+// it, since they cannot allow credentials.
+func (p *CORSPolicy) allowOriginHeader(origin string) string {
+	if containsString(p.AllowedOrigins, "*") {
+		return "*"
+	}
+	return origin
+}
+
+// allowsHeaders reports whether the comma-separated request headers are
+// allowed.
+func (p *CORSPolicy) allowsHeaders(headers string) bool {
+	if p.anyHeader() {
+		return true
+	}
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		allowed := false
+		for _, a := range p.AllowedHeaders {
+			if strings.EqualFold(a, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// anyHeader reports whether any request headers are allowed.
+func (p *CORSPolicy) anyHeader() bool {
+	if len(p.AllowedHeaders) == 0 {
+		return true
+	}
+	for _, h := range p.AllowedHeaders {
+		if h == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// handle wraps handle to add the CORS headers to actual cross-origin
+// responses.
+func (p *CORSPolicy) handle(handle httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		h := w.Header()
+		h.Add("Vary", "Origin")
+		if origin := req.Header.Get("Origin"); origin != "" && p.allowsOrigin(origin) {
+			h.Set("Access-Control-Allow-Origin", p.allowOriginHeader(origin))
+			if p.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if len(p.ExposedHeaders) > 0 {
+				h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+			}
+		}
+		handle(w, req, params)
+	}
+}
+
+// preflight answers a preflight request to path with an HTTP 204 No Content
+// response. The CORS headers are only set if the request is allowed.
+func (p *CORSPolicy) preflight(w http.ResponseWriter, req *http.Request, r *Router) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	defer w.WriteHeader(http.StatusNoContent)
+
+	origin := req.Header.Get("Origin")
+	method := req.Header.Get("Access-Control-Request-Method")
+	headers := req.Header.Get("Access-Control-Request-Headers")
+	if !p.allowsOrigin(origin) || !p.allowsHeaders(headers) {
+		return
+	}
+	methods := p.AllowedMethods
+	if len(methods) == 0 {
+		methods = r.allowedMethods(req.URL.Path)
+	}
+	if !containsString(methods, method) {
+		return
+	}
+
+	h.Set("Access-Control-Allow-Origin", p.allowOriginHeader(origin))
+	h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if p.anyHeader() {
+		if headers != "" {
+			h.Set("Access-Control-Allow-Headers", headers)
+		}
+	} else {
+		h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge/time.Second)))
+	}
+}
+
+// optionsRoute handles the OPTIONS requests to a path. It answers preflight
+// requests for the methods with a CORS policy, and passes other requests to
+// the OPTIONS route registered for the path, if any.
+type optionsRoute struct {
+	root   *Router
+	handle httprouter.Handle
+	cors   map[string]*CORSPolicy
+}
+
+func (o *optionsRoute) serve(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+	method := req.Header.Get("Access-Control-Request-Method")
+	if policy := o.cors[method]; policy != nil && req.Header.Get("Origin") != "" {
+		policy.preflight(w, req, o.root)
+		return
+	}
+	if o.handle != nil {
+		o.handle(w, req, params)
+		return
+	}
+	o.root.serveOptions(w, req)
+}
+
+// optionsRoute returns the optionsRoute of path, registering it with the
+// underlying router if necessary.
+func (r *Router) optionsRoute(path string) *optionsRoute {
+	root := r.root()
+	if o, ok := root.optionsRoutes[path]; ok {
+		return o
+	}
+	if root.optionsRoutes == nil {
+		root.optionsRoutes = make(map[string]*optionsRoute)
+	}
+	o := &optionsRoute{root: root, cors: make(map[string]*CORSPolicy)}
+	root.optionsRoutes[path] = o
+	root.router.Handle(http.MethodOptions, path, o.serve)
+	return o
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,125 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestCORS(t *testing.T) {
+	noop := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) { return jsonrest.M{}, nil }
+
+	r := jsonrest.NewRouter()
+	r.Get("/private", noop)
+
+	public := r.Group(jsonrest.WithCORS(jsonrest.CORSPolicy{AllowedOrigins: []string{"*"}}))
+	public.Get("/items", noop)
+
+	api := r.Group(jsonrest.WithCORS(jsonrest.CORSPolicy{
+		AllowedOrigins:   []string{"https://example.com", "https://*.example.org"},
+		AllowOrigin:      func(origin string) bool { return strings.HasSuffix(origin, ".test") },
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-Id"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}))
+	api.Get("/users/:id", noop)
+	api.Handle(http.MethodDelete, "/users/:id", noop)
+	api.Group().Post("/users", noop)
+
+	t.Run("actual request", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/users/1", nil, "application/json", map[string]string{"Origin": "https://api.example.org"})
+		assert.Equal(t, w.Result().StatusCode, 200)
+		h := w.Result().Header
+		assert.Equal(t, h.Get("Access-Control-Allow-Origin"), "https://api.example.org")
+		assert.Equal(t, h.Get("Access-Control-Allow-Credentials"), "true")
+		assert.Equal(t, h.Get("Access-Control-Expose-Headers"), "X-Request-Id")
+		assert.Equal(t, h.Get("Vary"), "Origin")
+
+		w = do(r, http.MethodGet, "/users/1", nil, "application/json", map[string]string{"Origin": "https://API.Example.ORG"})
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "https://API.Example.ORG")
+
+		w = do(r, http.MethodPost, "/users", strings.NewReader("{}"), "application/json", map[string]string{"Origin": "http://app.test"})
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "http://app.test")
+
+		w = do(r, http.MethodGet, "/items", nil, "application/json", map[string]string{"Origin": "https://any.com"})
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "*")
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Credentials"), "")
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.com", "https://example.org", "http://api.example.org"} {
+			w := do(r, http.MethodGet, "/users/1", nil, "application/json", map[string]string{"Origin": origin})
+			assert.Equal(t, w.Result().StatusCode, 200)
+			assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "")
+			assert.Equal(t, w.Result().Header.Get("Vary"), "Origin")
+		}
+	})
+
+	t.Run("no policy", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/private", nil, "application/json", map[string]string{"Origin": "https://example.com"})
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, w.Result().Header.Get("Vary"), "")
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		w := do(r, http.MethodOptions, "/users/1", nil, "", map[string]string{
+			"Origin":                         "https://example.com",
+			"Access-Control-Request-Method":  "DELETE",
+			"Access-Control-Request-Headers": "authorization",
+		})
+		assert.Equal(t, w.Result().StatusCode, 204)
+		h := w.Result().Header
+		assert.Equal(t, h.Get("Access-Control-Allow-Origin"), "https://example.com")
+		assert.Equal(t, h.Get("Access-Control-Allow-Methods"), "DELETE, GET, OPTIONS")
+		assert.Equal(t, h.Get("Access-Control-Allow-Headers"), "Authorization, Content-Type")
+		assert.Equal(t, h.Get("Access-Control-Allow-Credentials"), "true")
+		assert.Equal(t, h.Get("Access-Control-Max-Age"), "600")
+		assert.Equal(t, h["Vary"], []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"})
+	})
+
+	t.Run("preflight with disallowed header", func(t *testing.T) {
+		w := do(r, http.MethodOptions, "/users/1", nil, "", map[string]string{
+			"Origin":                         "https://example.com",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "X-Secret",
+		})
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "")
+	})
+
+	t.Run("preflight for method without policy", func(t *testing.T) {
+		w := do(r, http.MethodOptions, "/users/1", nil, "", map[string]string{
+			"Origin":                        "https://example.com",
+			"Access-Control-Request-Method": "PUT",
+		})
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "")
+		assert.Equal(t, w.Result().Header.Get("Allow"), "DELETE, GET, OPTIONS")
+	})
+
+	t.Run("preflight reflects requested headers", func(t *testing.T) {
+		w := do(r, http.MethodOptions, "/items", nil, "", map[string]string{
+			"Origin":                         "https://any.com",
+			"Access-Control-Request-Method":  "GET",
+			"Access-Control-Request-Headers": "X-Custom",
+		})
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Origin"), "*")
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Headers"), "X-Custom")
+		assert.Equal(t, w.Result().Header.Get("Access-Control-Allow-Methods"), "GET, OPTIONS")
+	})
+
+	t.Run("credentials with any origin", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		jsonrest.WithCORS(jsonrest.CORSPolicy{AllowedOrigins: []string{"*"}, AllowCredentials: true})
+	})
+}
@@ -0,0 +1,222 @@
+package jsonrest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// decodeOptions configure how Request.BindBody decodes request bodies.
+type decodeOptions struct {
+	// strict rejects unknown fields and data after the top-level value.
+	strict bool
+
+	// useNumber decodes JSON numbers into interface{} values as json.Number
+	// instead of float64.
+	useNumber bool
+
+	// maxSize is the maximum size of the body in bytes, or 0 if unlimited.
+	maxSize int64
+
+	// maxDepth is the maximum nesting depth of JSON objects and arrays, or 0
+	// if unlimited.
+	maxDepth int
+}
+
+// A BindOption overrides the router's decoding options for a single call to
+// Request.BindBody.
+type BindOption func(*decodeOptions)
+
+// BindStrict enables or disables strict decoding, which rejects unknown fields
+// and any data following the top-level JSON value.
+func BindStrict(strict bool) BindOption {
+	return func(o *decodeOptions) {
+		o.strict = strict
+	}
+}
+
+// BindUseNumber enables or disables decoding JSON numbers into interface{}
+// values as json.Number, so that large integers are not rounded.
+func BindUseNumber(useNumber bool) BindOption {
+	return func(o *decodeOptions) {
+		o.useNumber = useNumber
+	}
+}
+
+// BindMaxSize limits the size of the request body to n bytes. Larger bodies
+// are rejected with an HTTP 413 Request Entity Too Large error. If n is 0, the
+// size is unlimited.
+func BindMaxSize(n int64) BindOption {
+	return func(o *decodeOptions) {
+		o.maxSize = n
+	}
+}
+
+// BindMaxDepth limits the nesting depth of JSON objects and arrays in the
+// request body to n. If n is 0, the depth is unlimited.
+func BindMaxDepth(n int) BindOption {
+	return func(o *decodeOptions) {
+		o.maxDepth = n
+	}
+}
+
+// decodeBody decodes body into val using codec.
+func decodeBody(body io.Reader, val interface{}, codec Codec, opts decodeOptions) error {
+	if opts.maxSize > 0 {
+		body = &maxSizeReader{r: body, remaining: opts.maxSize, max: opts.maxSize}
+	}
+
+	_, isJSON := codec.(JSONCodec)
+	var err error
+	if isJSON {
+		err = decodeJSON(body, val, opts)
+	} else {
+		err = codec.Decode(body, val)
+	}
+	if err == nil {
+		return nil
+	}
+
+	if tooLarge := bodyTooLarge(err); tooLarge != nil {
+		return tooLarge
+	}
+	if !isJSON {
+		return BadRequest("malformed or unexpected request body").Wrap(err)
+	}
+	msg := "malformed or unexpected json"
+	if details := jsonErrorDetails(err); details != "" {
+		msg += ": " + details
+	}
+	return BadRequest(msg).Wrap(err)
+}
+
+// bodyTooLarge returns an HTTP 413 error if err was caused by a request body
+// exceeding the maximum size, and nil otherwise.
+func bodyTooLarge(err error) *HTTPError {
+	var tooLarge *bodyTooLargeError
+	if !errors.As(err, &tooLarge) {
+		return nil
+	}
+	msg := fmt.Sprintf("request body exceeds %d bytes", tooLarge.max)
+	return Error(413, "request_entity_too_large", msg).Wrap(err)
+}
+
+// errTrailingData is returned by strict decoding if the body contains data
+// after the top-level JSON value.
+var errTrailingData = errors.New("unexpected data after top-level value")
+
+// decodeJSON decodes the JSON body into val.
+func decodeJSON(body io.Reader, val interface{}, opts decodeOptions) error {
+	if opts.maxDepth > 0 {
+		body = &depthReader{r: body, max: opts.maxDepth}
+	}
+
+	dec := json.NewDecoder(body)
+	if opts.strict {
+		dec.DisallowUnknownFields()
+	}
+	if opts.useNumber {
+		dec.UseNumber()
+	}
+	if err := dec.Decode(val); err != nil {
+		return err
+	}
+
+	if opts.strict {
+		_, err := dec.Token()
+		switch err.(type) {
+		case *bodyTooLargeError, *depthError:
+			return err
+		}
+		if err != io.EOF {
+			return errTrailingData
+		}
+	}
+	return nil
+}
+
+// bodyTooLargeError is returned when the request body exceeds the maximum
+// size.
+type bodyTooLargeError struct {
+	max int64
+}
+
+func (e *bodyTooLargeError) Error() string {
+	return fmt.Sprintf("request body exceeds %d bytes", e.max)
+}
+
+// maxSizeReader returns a *bodyTooLargeError if more than max bytes are read
+// from r.
+type maxSizeReader struct {
+	r         io.Reader
+	remaining int64
+	max       int64
+}
+
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if m.remaining <= 0 {
+		// Probe for more data, to distinguish a body of exactly max bytes
+		// from a larger one.
+		var b [1]byte
+		if n, err := m.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &bodyTooLargeError{max: m.max}
+	}
+	if int64(len(p)) > m.remaining {
+		p = p[:m.remaining]
+	}
+	n, err := m.r.Read(p)
+	m.remaining -= int64(n)
+	return n, err
+}
+
+// depthError is returned when JSON objects and arrays are nested deeper than
+// the maximum depth.
+type depthError struct {
+	max int
+}
+
+func (e *depthError) Error() string {
+	return fmt.Sprintf("exceeds maximum nesting depth of %d", e.max)
+}
+
+// depthReader returns a *depthError if the JSON read from r nests objects and
+// arrays deeper than max. It tracks the depth as data is read, so that deeply
+// nested input is rejected before it is decoded.
+type depthReader struct {
+	r     io.Reader
+	max   int
+	depth int
+
+	inString bool
+	escaped  bool
+}
+
+func (d *depthReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	for _, c := range p[:n] {
+		switch {
+		case d.escaped:
+			d.escaped = false
+		case d.inString:
+			switch c {
+			case '\\':
+				d.escaped = true
+			case '"':
+				d.inString = false
+			}
+		case c == '"':
+			d.inString = true
+		case c == '{' || c == '[':
+			d.depth++
+			if d.depth > d.max {
+				return 0, &depthError{max: d.max}
+			}
+		case c == '}' || c == ']':
+			d.depth--
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,80 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestStrictDecoding(t *testing.T) {
+	r := jsonrest.NewRouter(
+		jsonrest.WithStrictDecoding(),
+		jsonrest.WithMaxBodySize(32),
+		jsonrest.WithMaxBodyDepth(2),
+	)
+	bind := func(opts ...jsonrest.BindOption) jsonrest.Endpoint {
+		return func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			var params map[string]interface{}
+			if err := r.BindBody(&params, opts...); err != nil {
+				return nil, err
+			}
+			return params, nil
+		}
+	}
+	r.Post("/strict", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var params struct {
+			ID int `json:"id"`
+		}
+		if err := r.BindBody(&params); err != nil {
+			return nil, err
+		}
+		return params, nil
+	})
+	r.Post("/map", bind())
+	r.Post("/lenient", bind(jsonrest.BindStrict(false), jsonrest.BindMaxSize(0), jsonrest.BindMaxDepth(0)))
+	r.Post("/number", bind(jsonrest.BindUseNumber(true), jsonrest.BindMaxSize(0)))
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantMsg    string
+	}{
+		{"valid", "/strict", `{"id": 1}`, 200, ""},
+		{"unknown field", "/strict", `{"id": 1, "name": "x"}`, 400, `malformed or unexpected json: unknown field "name"`},
+		{"trailing data", "/strict", `{"id": 1} {}`, 400, "malformed or unexpected json: unexpected data after top-level value"},
+		{"too large", "/map", `{"name": "` + strings.Repeat("x", 32) + `"}`, 413, "request body exceeds 32 bytes"},
+		{"exactly max size", "/map", `{"name": "` + strings.Repeat("x", 20) + `"}`, 200, ""},
+		{"too deep", "/map", `{"a": {"b": {"c": 1}}}`, 400, "malformed or unexpected json: exceeds maximum nesting depth of 2"},
+		{"brackets in strings", "/map", `{"a": {"b": "[[{{"}}`, 200, ""},
+		{"per call options", "/lenient", `{"a": {"b": {"c": "` + strings.Repeat("x", 32) + `"}}} {}`, 200, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(r, http.MethodPost, tt.path, strings.NewReader(tt.body), "application/json", nil)
+			assert.Equal(t, w.Result().StatusCode, tt.wantStatus)
+			if tt.wantMsg != "" {
+				var body struct {
+					Error struct {
+						Message string `json:"message"`
+					} `json:"error"`
+				}
+				assert.Must(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, body.Error.Message, tt.wantMsg)
+			}
+		})
+	}
+
+	t.Run("use number", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/number", strings.NewReader(`{"id": 9007199254740993}`), "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Body.String(), "{\n  \"id\": 9007199254740993\n}\n")
+	})
+}
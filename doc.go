@@ -6,7 +6,9 @@
 //
 // If an endpoint returns a value along with a nil error, the value will be
 // rendered to the client as JSON with status code 200. You can also return
-// a Response object if you need another type of success code (e.g. 204).
+// a Response object if you need another type of success code (e.g. 204), or to
+// set response headers and cookies. Created and Redirect are provided for the
+// common cases.
 //
 // If an error is returned, it will be sanitized and returned to the client as
 // json. Errors generated by a call to `jsonrest.Error(status, code, message)`
@@ -35,4 +37,8 @@
 //             return next(ctx, req)
 //         }
 //     }
+//
+// Middleware runs before the response is written; use Request.OnResponse to
+// observe the final status code, or the AccessLog middleware for structured
+// access logs.
 package jsonrest
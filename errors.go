@@ -1,7 +1,10 @@
 package jsonrest
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -47,6 +50,12 @@ func UnprocessableEntity(msg string) *HTTPError {
 	return Error(http.StatusUnprocessableEntity, "unprocessable_entity", msg)
 }
 
+// unsupportedMediaType returns an HTTP 415 Unsupported Media Type error for
+// the given Content-Type.
+func unsupportedMediaType(contentType string) *HTTPError {
+	return Error(http.StatusUnsupportedMediaType, "unsupported_media_type", fmt.Sprintf("unsupported content type %q", contentType))
+}
+
 // unknownError is returned for an internal server error.
 var unknownError = &HTTPError{
 	Code:    "unknown_error",
@@ -61,6 +70,24 @@ type HTTPError struct {
 	Details []string
 	Status  int
 
+	// Fields describes the invalid fields of the request body, if any.
+	Fields []FieldError
+
+	// Type is a URI reference identifying the problem type. It is only
+	// rendered when the router is configured with WithProblemDetails, and
+	// defaults to "about:blank".
+	Type string
+
+	// Extensions are additional members rendered alongside the standard
+	// members of a problem details object. They are only rendered when the
+	// router is configured with WithProblemDetails.
+	Extensions map[string]interface{}
+
+	// RequestID identifies the request which caused the error. It is set
+	// when rendering errors for requests handled by the RequestID middleware
+	// with IncludeRequestIDInErrors.
+	RequestID string
+
 	wrapped error
 }
 
@@ -73,17 +100,85 @@ func (err *HTTPError) StatusCode() int {
 func (err *HTTPError) MarshalJSON() ([]byte, error) {
 	var wp struct {
 		Error struct {
-			Code    string   `json:"code"`
-			Message string   `json:"message"`
-			Details []string `json:"details,omitempty"`
+			Code      string       `json:"code"`
+			Message   string       `json:"message"`
+			Details   []string     `json:"details,omitempty"`
+			Fields    []FieldError `json:"fields,omitempty"`
+			RequestID string       `json:"request_id,omitempty"`
 		} `json:"error"`
 	}
 	wp.Error.Code = err.Code
 	wp.Error.Message = err.Message
 	wp.Error.Details = err.Details
+	wp.Error.Fields = err.Fields
+	wp.Error.RequestID = err.RequestID
 	return json.Marshal(wp)
 }
 
+// ProblemDetails converts the error to its RFC 7807 representation. The
+// instance identifies the specific occurrence of the problem, typically the
+// request path.
+func (err *HTTPError) ProblemDetails(instance string) *ProblemDetails {
+	ext := make(map[string]interface{}, len(err.Extensions)+2)
+	for k, v := range err.Extensions {
+		ext[k] = v
+	}
+	ext["code"] = err.Code
+	if len(err.Details) > 0 {
+		ext["details"] = err.Details
+	}
+	if len(err.Fields) > 0 {
+		ext["errors"] = err.Fields
+	}
+	if err.RequestID != "" {
+		ext["request_id"] = err.RequestID
+	}
+	return &ProblemDetails{
+		Type:       err.Type,
+		Title:      http.StatusText(err.Status),
+		Status:     err.Status,
+		Detail:     err.Message,
+		Instance:   instance,
+		Extensions: ext,
+	}
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (err *HTTPError) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type details struct {
+		Detail []string `xml:"detail"`
+	}
+	type field struct {
+		Pointer string `xml:"pointer,attr"`
+		Message string `xml:",chardata"`
+	}
+	type fields struct {
+		Field []field `xml:"field"`
+	}
+	wp := struct {
+		XMLName   xml.Name `xml:"error"`
+		Code      string   `xml:"code"`
+		Message   string   `xml:"message"`
+		Details   *details `xml:"details,omitempty"`
+		Fields    *fields  `xml:"fields,omitempty"`
+		RequestID string   `xml:"request_id,omitempty"`
+	}{
+		Code:      err.Code,
+		Message:   err.Message,
+		RequestID: err.RequestID,
+	}
+	if len(err.Details) > 0 {
+		wp.Details = &details{err.Details}
+	}
+	if len(err.Fields) > 0 {
+		wp.Fields = &fields{}
+		for _, f := range err.Fields {
+			wp.Fields.Field = append(wp.Fields.Field, field(f))
+		}
+	}
+	return e.Encode(wp)
+}
+
 // Error implements the error interface.
 func (err *HTTPError) Error() string {
 	return fmt.Sprintf("jsonrest: %v: %v", err.Code, err.Message)
@@ -106,21 +201,123 @@ func (err *HTTPError) Cause() error {
 	return err.wrapped
 }
 
+// ProblemDetailsContentType is the media type of an RFC 7807 problem details
+// JSON object.
+const ProblemDetailsContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 7807 (and RFC 9457) problem details object. It
+// implements HTTPErrorResponse, so it may also be returned directly by an
+// endpoint.
+type ProblemDetails struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+
+	// Extensions are additional members of the problem details object. They
+	// cannot override the standard members above.
+	Extensions map[string]interface{}
+}
+
+// StatusCode implements the HTTPErrorResponse interface.
+func (p *ProblemDetails) StatusCode() int {
+	return p.Status
+}
+
+// Error implements the error interface.
+func (p *ProblemDetails) Error() string {
+	return fmt.Sprintf("jsonrest: %v: %v", p.Title, p.Detail)
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		m[k] = v
+	}
+	m["type"] = p.Type
+	if p.Type == "" {
+		m["type"] = "about:blank"
+	}
+	m["title"] = p.Title
+	m["status"] = p.Status
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+// An ErrorMapper translates a domain error, such as sql.ErrNoRows, into an
+// HTTPErrorResponse. It returns nil if it does not recognise the error.
+type ErrorMapper func(err error) HTTPErrorResponse
+
+// MapError returns an ErrorMapper that translates any error matching target,
+// as reported by errors.Is, into a copy of httpErr wrapping the original error.
+func MapError(target error, httpErr *HTTPError) ErrorMapper {
+	return func(err error) HTTPErrorResponse {
+		if !errors.Is(err, target) {
+			return nil
+		}
+		e := *httpErr // shallow copy
+		return e.Wrap(err)
+	}
+}
+
 // translateError coerces err into an HTTPErrorResponse that can be marshaled directly
 // to the client.
-func translateError(err error, dumpInternalError bool) HTTPErrorResponse {
-	errResponse, ok := err.(HTTPErrorResponse)
-	if !ok {
-		e := *unknownError
-		httpErr := &(e) // shallow copy
-		if dumpInternalError {
-			httpErr.Details = dumpError(err)
+func translateError(err error, mappers []ErrorMapper, dumpInternalError bool) HTTPErrorResponse {
+	if errResponse := lookupError(err, mappers); errResponse != nil {
+		return errResponse
+	}
+
+	e := *unknownError
+	httpErr := &(e) // shallow copy
+	if dumpInternalError {
+		httpErr.Details = dumpError(err)
+	}
+	return httpErr
+}
+
+// lookupError returns the first HTTPErrorResponse found in the error chain,
+// otherwise the result of the first mapper to recognise err. It returns nil
+// if err is an internal error.
+func lookupError(err error, mappers []ErrorMapper) HTTPErrorResponse {
+	var errResponse HTTPErrorResponse
+	if errors.As(err, &errResponse) {
+		return errResponse
+	}
+	for _, m := range mappers {
+		if errResponse := m(err); errResponse != nil {
+			return errResponse
 		}
-		errResponse = httpErr
 	}
-	return errResponse
+	return nil
+}
+
+// ErrorEvent describes an internal error or a panic raised while serving a
+// request.
+type ErrorEvent struct {
+	// Err is the error returned by the endpoint. It is nil if the endpoint
+	// panicked.
+	Err error
+
+	// Panic is the recovered panic value, if the endpoint panicked.
+	Panic interface{}
+
+	// Stack is the stack trace captured when the panic was recovered.
+	Stack []byte
 }
 
+// An ErrorHandler is called when an endpoint returns an error that cannot be
+// rendered to the client as-is, or panics. It may return an error to be
+// rendered in place of the default unknown error; returning nil keeps the
+// default response.
+type ErrorHandler func(ctx context.Context, req *Request, ev ErrorEvent) error
+
 // dumpError formats the error suitable for viewing in a JSON response for local
 // debugging.
 func dumpError(err error) []string {
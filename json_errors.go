@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 )
 
@@ -11,6 +12,8 @@ import (
 // JSON unmarshal error in terms that are safe to return to the caller.
 func jsonErrorDetails(err error) string {
 	switch err := err.(type) {
+	case *depthError:
+		return err.Error()
 	case *json.SyntaxError:
 		return fmt.Sprintf("offset %d: %s", err.Offset, err.Error())
 	case *json.UnmarshalTypeError:
@@ -20,6 +23,14 @@ func jsonErrorDetails(err error) string {
 		}
 		return fmt.Sprintf("offset %d: cannot unmarshal %s to %q%s", err.Offset, err.Value, err.Field, typeSuffix)
 	default:
+		if err == errTrailingData {
+			return err.Error()
+		}
+		// The error returned for unknown fields by a json.Decoder with
+		// DisallowUnknownFields has no type of its own.
+		if msg := err.Error(); strings.HasPrefix(msg, "json: unknown field ") {
+			return strings.TrimPrefix(msg, "json: ")
+		}
 		return ""
 	}
 }
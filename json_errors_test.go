@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 )
@@ -58,6 +59,46 @@ func TestJSONErrorDetails(t *testing.T) {
 	}
 }
 
+func TestJSONErrorDetailsStrict(t *testing.T) {
+	var dest struct {
+		Name string `json:"name"`
+	}
+	tests := []struct {
+		json string
+		opts decodeOptions
+		err  string
+	}{
+		{
+			json: `{"name": "a", "age": 1}`,
+			opts: decodeOptions{strict: true},
+			err:  `unknown field "age"`,
+		},
+		{
+			json: `{"name": "a"} x`,
+			opts: decodeOptions{strict: true},
+			err:  `unexpected data after top-level value`,
+		},
+		{
+			json: `{"name": [[1]]}`,
+			opts: decodeOptions{maxDepth: 2},
+			err:  `exceeds maximum nesting depth of 2`,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(fmt.Sprintf("test %d", i), func(t *testing.T) {
+			err := decodeJSON(strings.NewReader(tt.json), &dest, tt.opts)
+			if err == nil {
+				t.Fatal("unexpected nil error")
+			}
+
+			got := jsonErrorDetails(err)
+			if got != tt.err {
+				t.Errorf("incorrect error:\ngot:  %s\nwant: %s", got, tt.err)
+			}
+		})
+	}
+}
+
 func TestJSONType(t *testing.T) {
 	type Enum int
 	type Person struct{}
@@ -1,16 +1,21 @@
 package jsonrest
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"mime/multipart"
 	"net/http"
 	"net/url"
+	"reflect"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/NYTimes/gziphandler"
 	"github.com/julienschmidt/httprouter"
@@ -28,6 +33,28 @@ type Request struct {
 	req            *http.Request
 	responseWriter http.ResponseWriter
 	route          string
+	router         *Router
+
+	// response records the response written for the request, which is
+	// reported to the onResponse callbacks.
+	response responseRecorder
+
+	// errorCode is the code of the error rendered for the request, if any.
+	errorCode string
+
+	// notAcceptable is set if none of the media types accepted by the client
+	// are supported.
+	notAcceptable bool
+
+	// mu guards the fields below, which are set by middleware. Middleware
+	// runs concurrently with the response to the request if it times out.
+	mu         sync.Mutex
+	onResponse []func(ResponseInfo)
+
+	// id is the request ID set by the RequestID middleware, and idInErrors
+	// whether it is included in error responses.
+	id         string
+	idInErrors bool
 }
 
 // BasicAuth returns the username and password, if the request uses HTTP Basic
@@ -36,19 +63,45 @@ func (r *Request) BasicAuth() (username, password string, ok bool) {
 	return r.req.BasicAuth()
 }
 
-// BindBody unmarshals the request body into the given value.
-func (r *Request) BindBody(val interface{}) error {
-	defer r.req.Body.Close()
-	if err := json.NewDecoder(r.req.Body).Decode(val); err != nil {
-		msg := "malformed or unexpected json"
-		if details := jsonErrorDetails(err); details != "" {
-			msg += ": " + details
-		}
-		return BadRequest(msg).Wrap(err)
+// BindBody unmarshals the request body into the given value, using the codec
+// registered for the request Content-Type. If there is no such codec, an HTTP
+// 415 Unsupported Media Type error is returned. If the router is configured
+// WithValidation, the value is then checked by Validate.
+//
+// The body is decoded according to the router's decoding options, which may
+// be overridden for this call by opts.
+func (r *Request) BindBody(val interface{}, opts ...BindOption) error {
+	if err := r.decodeBody(val, opts...); err != nil {
+		return err
+	}
+	if r.router != nil && r.router.validation {
+		return Validate(val)
 	}
 	return nil
 }
 
+// decodeBody unmarshals the request body into the given value as BindBody
+// does, without validating it.
+func (r *Request) decodeBody(val interface{}, opts ...BindOption) error {
+	defer r.req.Body.Close()
+
+	router := r.router
+	if router == nil {
+		router = &Router{}
+	}
+	contentType := r.req.Header.Get("Content-Type")
+	codec, ok := router.requestCodec(contentType)
+	if !ok {
+		return unsupportedMediaType(contentType)
+	}
+
+	decodeOpts := router.decodeOptions
+	for _, opt := range opts {
+		opt(&decodeOpts)
+	}
+	return decodeBody(r.req.Body, val, codec, decodeOpts)
+}
+
 // FormFile returns the first file for the provided form key.
 func (r *Request) FormFile(name string, maxMultipartMemory int64) (multipart.File, *multipart.FileHeader, error) {
 	if err := r.req.ParseMultipartForm(maxMultipartMemory); err != nil {
@@ -95,6 +148,10 @@ func (r *Request) Method() string {
 
 // SetResponseHeader sets a response header.
 func (r *Request) SetResponseHeader(key, val string) {
+	if tw, ok := r.responseWriter.(*timeoutWriter); ok {
+		tw.setHeader(key, val)
+		return
+	}
 	r.responseWriter.Header().Set(key, val)
 }
 
@@ -109,10 +166,53 @@ func (r *Request) URL() *url.URL {
 }
 
 // Response is a type that can be returned by the endpoint for setting a custom
-// HTTP success status code with the response body.
+// HTTP success status code, headers and cookies with the response body.
 type Response struct {
 	Body       interface{}
 	StatusCode int
+
+	// Header contains additional headers to be written with the response.
+	Header http.Header
+
+	// Cookies are written to the response as Set-Cookie headers.
+	Cookies []*http.Cookie
+}
+
+// Created returns an HTTP 201 Created Response with the Location header set.
+func Created(location string, body interface{}) Response {
+	return Response{
+		Body:       body,
+		StatusCode: http.StatusCreated,
+		Header:     http.Header{"Location": {location}},
+	}
+}
+
+// Redirect returns a Response redirecting the client to url with the given
+// 3xx status code, e.g. http.StatusFound or http.StatusSeeOther. It panics if
+// status is not a redirection status.
+func Redirect(status int, url string) Response {
+	if status < 300 || status > 399 {
+		panic(fmt.Sprintf("jsonrest: invalid redirect status %d", status))
+	}
+	return Response{
+		StatusCode: status,
+		Header:     http.Header{"Location": {url}},
+	}
+}
+
+// header returns the response headers, including the Set-Cookie headers of
+// the cookies.
+func (res Response) header() http.Header {
+	h := res.Header.Clone()
+	if h == nil && len(res.Cookies) > 0 {
+		h = make(http.Header)
+	}
+	for _, c := range res.Cookies {
+		if v := c.String(); v != "" {
+			h.Add("Set-Cookie", v)
+		}
+	}
+	return h
 }
 
 // M is a shorthand for map[string]interface{}. Responses from the server may be
@@ -139,6 +239,12 @@ type Endpoint func(ctx context.Context, r *Request) (interface{}, error)
 //	}
 type Middleware func(Endpoint) Endpoint
 
+// applyRoute implements RouteOption, so that middleware may be applied to a
+// single route.
+func (m Middleware) applyRoute(rt *route) {
+	rt.middleware = append(rt.middleware, m)
+}
+
 // A Router is an http.Handler that routes incoming requests to registered
 // endpoints.
 type Router struct {
@@ -152,6 +258,15 @@ type Router struct {
 	// option to enable/disable gzip compression
 	enableCompression bool
 
+	// option to render errors as RFC 7807 problem details
+	problemDetails bool
+
+	// option to validate values bound by BindBody
+	validation bool
+
+	// options controlling how BindBody decodes request bodies
+	decodeOptions decodeOptions
+
 	// gzipHandler is a handler that wraps the router and compresses responses
 	gzipHandler func(http.Handler) http.Handler
 
@@ -159,19 +274,111 @@ type Router struct {
 	// route is found. If it is not set, notFoundHandler is used.
 	notFound http.Handler
 
-	router     *httprouter.Router
-	middleware []Middleware
-	options    []Option
-	parent     *Router
+	// methodNotAllowed is a configurable http.Handler which is called when a
+	// route matches the path but not the method. If it is not set,
+	// methodNotAllowedHandler is used.
+	methodNotAllowed http.Handler
+
+	// cors is the CORS policy of the router's routes, if any.
+	cors *CORSPolicy
+
+	// metrics records the requests to the router's routes, if set.
+	metrics *Metrics
+
+	// tracer traces the requests to the router's routes, if set.
+	tracer Tracer
+
+	// timeout bounds the time taken by the router's endpoints, if positive.
+	timeout time.Duration
+
+	// clientTimeoutHeader is the header in which clients may set a shorter
+	// timeout, up to maxClientTimeout.
+	clientTimeoutHeader string
+	maxClientTimeout    time.Duration
+
+	// codecs are the codecs available in addition to JSON.
+	codecs []Codec
+
+	// contentTypes are the media ranges accepted for request bodies. If nil,
+	// JSON and the media types of codecs are accepted.
+	contentTypes []string
+
+	// errorMappers translate domain errors into HTTP errors.
+	errorMappers []ErrorMapper
+
+	// errorHandler is notified of internal errors and panics. If it is not
+	// set, panics are logged with the standard logger.
+	errorHandler ErrorHandler
+
+	// openAPIInfo describes the API in generated OpenAPI documents.
+	openAPIInfo OpenAPIInfo
+
+	// routes are the routes registered with the router and its groups. It is
+	// only used by the root router.
+	routes   []*route
+	routesMu sync.Mutex
+
+	// methods are the distinct methods of the registered routes, so that
+	// the methods allowed for a path are found without scanning every route.
+	// It is only used by the root router, and guarded by routesMu.
+	methods map[string]bool
+
+	// optionsRoutes handle the OPTIONS requests to the paths which have an
+	// OPTIONS route or a CORS policy. It is only used by the root router.
+	optionsRoutes map[string]*optionsRoute
+
+	// middlewareVersion is incremented whenever middleware is added to the
+	// router or any of its groups, so that composed middleware chains can be
+	// rebuilt. It is only used by the root router.
+	middlewareVersion uint32
+
+	// forRoute is set while the options given for a single route are applied
+	// to its router, so that options configuring a whole router can reject
+	// them.
+	forRoute bool
+
+	router         *httprouter.Router
+	middleware     []Middleware
+	httpMiddleware []func(http.Handler) http.Handler
+	options        []Option
+	parent         *Router
 }
 
 type Option func(*Router)
 
+// applyRoute implements RouteOption, so that options may be overridden for a
+// single route. Options which only configure a whole router, such as
+// WithNotFoundHandler or WithCORS, panic when the route is registered.
+func (o Option) applyRoute(rt *route) {
+	rt.options = append(rt.options, o)
+}
+
 // WithNotFoundHandler is an Option available for NewRouter to configure the
 // not found handler.
 func WithNotFoundHandler(h http.Handler) Option {
-	return func(r *Router) {
+	return routerOption("WithNotFoundHandler", func(r *Router) {
 		r.notFound = h
+	})
+}
+
+// WithMethodNotAllowedHandler is an Option available for NewRouter to
+// configure the handler called when a route matches the request path but not
+// its method. The Allow header is set before the handler is called.
+func WithMethodNotAllowedHandler(h http.Handler) Option {
+	return routerOption("WithMethodNotAllowedHandler", func(r *Router) {
+		r.methodNotAllowed = h
+	})
+}
+
+// routerOption returns an Option which applies fn to a router or group, and
+// which panics if it is given for a single route, since the option only
+// configures a whole router.
+func routerOption(name string, fn func(*Router)) Option {
+	return func(r *Router) {
+		if r.forRoute {
+			panic("jsonrest: " + name + " cannot be given for a single route")
+		}
+		fn(r)
 	}
 }
 
@@ -183,6 +390,101 @@ func WithDisableJSONIndent() Option {
 	}
 }
 
+// WithProblemDetails is an Option available for NewRouter to render every
+// HTTPError as an RFC 7807 application/problem+json object instead of the
+// default error envelope. The HTTPError code and details are rendered as
+// extension members.
+func WithProblemDetails() Option {
+	return func(r *Router) {
+		r.problemDetails = true
+	}
+}
+
+// WithCodec is an Option available for NewRouter to register a Codec. The
+// codec is used to encode responses to clients whose Accept header prefers its
+// media type, and to decode request bodies with a matching Content-Type. JSON
+// is always supported.
+func WithCodec(c Codec) Option {
+	return func(r *Router) {
+		r.codecs = append(r.codecs, c)
+	}
+}
+
+// WithContentTypes is an Option available for NewRouter to configure the media
+// types accepted for request bodies, e.g. "application/json". Media ranges
+// such as "image/*" and "*/*" may be used. Requests with a body of any other
+// Content-Type are rejected with an HTTP 415 Unsupported Media Type error
+// before reaching the endpoint, after its middleware. The given types replace
+// the default, which accepts JSON (including +json media types), the media
+// types of any registered codecs, and multipart/form-data and
+// application/x-www-form-urlencoded forms.
+func WithContentTypes(types ...string) Option {
+	return func(r *Router) {
+		r.contentTypes = types
+	}
+}
+
+// WithValidation is an Option available for NewRouter to validate every value
+// bound by Request.BindBody with Validate.
+func WithValidation() Option {
+	return func(r *Router) {
+		r.validation = true
+	}
+}
+
+// WithStrictDecoding is an Option available for NewRouter to reject request
+// bodies with unknown fields or data following the top-level JSON value.
+func WithStrictDecoding() Option {
+	return func(r *Router) {
+		r.decodeOptions.strict = true
+	}
+}
+
+// WithUseNumber is an Option available for NewRouter to decode JSON numbers
+// into interface{} values as json.Number, so that large integers are not
+// rounded.
+func WithUseNumber() Option {
+	return func(r *Router) {
+		r.decodeOptions.useNumber = true
+	}
+}
+
+// WithMaxBodySize is an Option available for NewRouter to limit the size of
+// request bodies decoded by BindBody to n bytes. Larger bodies are rejected
+// with an HTTP 413 Request Entity Too Large error.
+func WithMaxBodySize(n int64) Option {
+	return func(r *Router) {
+		r.decodeOptions.maxSize = n
+	}
+}
+
+// WithMaxBodyDepth is an Option available for NewRouter to limit the nesting
+// depth of JSON objects and arrays in request bodies decoded by BindBody.
+func WithMaxBodyDepth(n int) Option {
+	return func(r *Router) {
+		r.decodeOptions.maxDepth = n
+	}
+}
+
+// WithErrorMapper is an Option available for NewRouter to register an
+// ErrorMapper. Errors returned by an endpoint that do not wrap an
+// HTTPErrorResponse are passed to each registered mapper in order, and the
+// first non-nil result is rendered to the client.
+func WithErrorMapper(m ErrorMapper) Option {
+	return func(r *Router) {
+		r.errorMappers = append(r.errorMappers, m)
+	}
+}
+
+// WithErrorHandler is an Option available for NewRouter to configure the
+// handler notified of internal errors and panics, e.g. to report them to an
+// error tracker.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(r *Router) {
+		r.errorHandler = h
+	}
+}
+
 // WithCompressionEnabled is an Option available for NewRouter to configure gzip compression.
 // The compression level can be gzip.DefaultCompression, gzip.NoCompression, gzip.HuffmanOnly
 // or any integer value between gzip.BestSpeed and gzip.BestCompression inclusive.
@@ -193,6 +495,15 @@ func WithCompressionEnabled(level int) Option {
 	}
 }
 
+// WithCompressionDisabled is an Option which disables gzip compression. It is
+// intended for groups or routes of a router created with
+// WithCompressionEnabled, such as a route streaming a large export.
+func WithCompressionDisabled() Option {
+	return func(r *Router) {
+		r.enableCompression = false
+	}
+}
+
 // NewRouter returns a new initialized Router.
 func NewRouter(options ...Option) *Router {
 	hr := httprouter.New()
@@ -208,13 +519,39 @@ func NewRouter(options ...Option) *Router {
 	} else {
 		hr.NotFound = r.notFound
 	}
+	if r.enableCompression {
+		hr.NotFound = r.gzipHandler(hr.NotFound)
+	}
+
+	// OPTIONS requests are answered by allowedHandler, which httprouter calls
+	// as the method not allowed handler when it does not handle them itself.
+	hr.HandleOPTIONS = false
+	if r.methodNotAllowed == nil {
+		hr.MethodNotAllowed = allowedHandler(r, methodNotAllowedHandler(r))
+	} else {
+		hr.MethodNotAllowed = allowedHandler(r, r.methodNotAllowed)
+	}
 
 	return r
 }
 
+// routeGroup creates the router of a single route given options. It panics if
+// any of the options only configures a whole router.
+func (r *Router) routeGroup(options []Option) *Router {
+	g := r.Group()
+	g.forRoute = true
+	for _, option := range options {
+		option(g)
+		g.options = append(g.options, option)
+	}
+	g.forRoute = false
+	return g
+}
+
 // Use registers a middleware to be used for all routes.
 func (r *Router) Use(ms ...Middleware) {
 	r.middleware = append(r.middleware, ms...)
+	atomic.AddUint32(&r.root().middlewareVersion, 1)
 }
 
 // Group creates a new subrouter, representing a group of routes, from the given
@@ -226,7 +563,7 @@ func (r *Router) Group(groupOptions ...Option) *Router {
 		parent:     r,
 		router:     r.router,
 		DumpErrors: r.DumpErrors,
-		options:    r.options,
+		options:    append([]Option(nil), r.options...),
 	}
 	for _, option := range r.options {
 		option(newRouter)
@@ -238,130 +575,515 @@ func (r *Router) Group(groupOptions ...Option) *Router {
 	return newRouter
 }
 
-// RouteMap is a map of a method-path pair to an endpoint. For example:
+// RouteMap is a map of a method-path pair to a route entry. For example:
 //
 //	jsonrest.RouteMap{
-//	    "GET  /ping": pingEndpoint,
-//	    "HEAD /api/check": checkEndpoint,
-//	    "POST /api/update": updateEndpoint,
-//	    "PUT  /api/update": updateEndpoint,
+//	    "GET  /ping":       {Endpoint: pingEndpoint},
+//	    "HEAD /api/check":  {Endpoint: checkEndpoint},
+//	    "POST /api/update": {Endpoint: updateEndpoint},
+//	    "GET  /export":     jsonrest.Route(export, jsonrest.WithCompressionDisabled()),
 //	}
-type RouteMap map[string]Endpoint
+type RouteMap map[string]RouteEntry
 
-// Routes registers all routes in the route map. It x panic if an entry is
-// malformed.
-func (r *Router) Routes(m RouteMap) {
+// A RouteEntry is an entry of a RouteMap: an endpoint along with the options
+// of its route.
+type RouteEntry struct {
+	Endpoint Endpoint
+	Options  []RouteOption
+}
+
+// Route returns a RouteEntry for endpoint with the given route options.
+func Route(endpoint Endpoint, opts ...RouteOption) RouteEntry {
+	return RouteEntry{Endpoint: endpoint, Options: opts}
+}
+
+// Routes registers all routes in the route map, applying the options of each
+// entry and then opts to its route. It panics if an entry is malformed.
+func (r *Router) Routes(m RouteMap, opts ...RouteOption) {
 	for p, e := range m {
 		parts := strings.Fields(p)
 		if len(parts) != 2 {
 			panic(fmt.Sprintf("invalid RouteMap: %q", p))
 		}
 		method, path := parts[0], parts[1]
-		r.Handle(method, path, e)
+		r.Handle(method, path, e.Endpoint, append(e.Options[:len(e.Options):len(e.Options)], opts...)...)
+	}
+}
+
+// Get is a shortcut for router.Handle(http.MethodGet, path, endpoint, opts...).
+func (r *Router) Get(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodGet, path, endpoint, opts...)
+}
+
+// Head is a shortcut for router.Handle(http.MethodHead, path, endpoint, opts...).
+func (r *Router) Head(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodHead, path, endpoint, opts...)
+}
+
+// Post is a shortcut for router.Handle(http.MethodPost, path, endpoint, opts...).
+func (r *Router) Post(path string, endpoint Endpoint, opts ...RouteOption) {
+	r.Handle(http.MethodPost, path, endpoint, opts...)
+}
+
+// Handle registers a new endpoint to handle the given path and method. It
+// panics if the router is configured WithValidation and the request type of
+// the route, if given with RequestType or TypesOf, has invalid "validate"
+// tags.
+//
+// Middleware given in opts is applied to this route only, after the
+// middleware of the router. Options given in opts override those of the
+// router for this route only, e.g.
+//
+//	r.Get("/export", export, jsonrest.Middleware(auth), jsonrest.WithCompressionDisabled())
+func (r *Router) Handle(method, path string, endpoint Endpoint, opts ...RouteOption) {
+	rt := &route{method: method, path: path, router: r}
+	for _, opt := range opts {
+		opt.applyRoute(rt)
+	}
+	if len(rt.options) > 0 {
+		rt.router = r.routeGroup(rt.options)
+	}
+	if rt.router.validation && rt.requestType != nil {
+		if err := CheckValidation(rt.requestType); err != nil {
+			panic(err)
+		}
+	}
+	r.root().addRoute(rt)
+
+	endpoint = negotiated(endpoint)
+	for i := len(rt.middleware) - 1; i >= 0; i-- {
+		endpoint = rt.middleware[i](endpoint)
+	}
+	endpoint = applyMiddleware(endpoint, rt.router)
+	r.register(rt, endpointToHandler(endpoint, path, rt.router))
+}
+
+// HandleHTTP registers a standard http.Handler to handle the given path and
+// method, such as a Metrics handler. The net/http middleware, CORS policy,
+// metrics, tracing and compression of the router apply to the route, but its
+// Middleware does not, and the route is not included in OpenAPI documents.
+func (r *Router) HandleHTTP(method, path string, h http.Handler) {
+	rt := &route{method: method, path: path, router: r, hidden: true}
+	r.root().addRoute(rt)
+	r.register(rt, func(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+		h.ServeHTTP(w, req)
+	})
+}
+
+// register registers handle with the underlying router for rt, applying the
+// net/http middleware, CORS policy, metrics, tracing and compression of its
+// router.
+func (r *Router) register(rt *route, handle httprouter.Handle) {
+	handle = applyHTTPMiddleware(handle, rt.router)
+	if cors := rt.router.cors; cors != nil {
+		handle = cors.handle(handle)
+		r.optionsRoute(rt.path).cors[rt.method] = cors
+	}
+	handle = rt.router.instrument(rt.method, rt.path, handle)
+	handle = rt.router.compress(handle)
+
+	// OPTIONS routes share their path with the preflight requests of CORS.
+	if rt.method == http.MethodOptions {
+		r.optionsRoute(rt.path).handle = handle
+		return
+	}
+	r.router.Handle(rt.method, rt.path, handle)
+}
+
+// compress wraps handle to compress its responses, if compression is enabled.
+func (r *Router) compress(handle httprouter.Handle) httprouter.Handle {
+	if !r.enableCompression {
+		return handle
 	}
+	h := r.gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		params, _ := req.Context().Value(paramsKey{}).(httprouter.Params)
+		handle(w, req, params)
+	}))
+
+	// The URL parameters are passed through the context, so that the gzip
+	// handler is only built once.
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		ctx := context.WithValue(req.Context(), paramsKey{}, params)
+		h.ServeHTTP(w, req.WithContext(ctx))
+	}
+}
+
+// A RouteOption configures a single route registered with Handle. Middleware
+// and Option values are also RouteOptions, except for the options which only
+// configure a whole router.
+type RouteOption interface {
+	applyRoute(*route)
+}
+
+// routeOptionFunc adapts a function to the RouteOption interface.
+type routeOptionFunc func(*route)
+
+func (f routeOptionFunc) applyRoute(rt *route) {
+	f(rt)
 }
 
-// Get is a shortcut for router.Handle(http.MethodGet, path, endpoint).
-func (r *Router) Get(path string, endpoint Endpoint) {
-	r.Handle(http.MethodGet, path, endpoint)
+// route is a route registered with a Router, along with its documentation.
+type route struct {
+	method string
+	path   string
+	router *Router
+
+	summary      string
+	description  string
+	tags         []string
+	requestType  reflect.Type
+	responseType reflect.Type
+	status       int
+	errors       []*HTTPError
+	hidden       bool
+
+	// middleware and options apply to this route only.
+	middleware []Middleware
+	options    []Option
+}
+
+// root returns the router at the top of the group hierarchy.
+func (r *Router) root() *Router {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
 }
 
-// Head is a shortcut for router.Handle(http.MethodHead, path, endpoint).
-func (r *Router) Head(path string, endpoint Endpoint) {
-	r.Handle(http.MethodHead, path, endpoint)
+// addRoute records a registered route.
+func (r *Router) addRoute(rt *route) {
+	r.routesMu.Lock()
+	defer r.routesMu.Unlock()
+	r.routes = append(r.routes, rt)
+	if r.methods == nil {
+		r.methods = make(map[string]bool)
+	}
+	r.methods[rt.method] = true
 }
 
-// Post is a shortcut for router.Handle(http.MethodPost, path, endpoint).
-func (r *Router) Post(path string, endpoint Endpoint) {
-	r.Handle(http.MethodPost, path, endpoint)
+// registeredMethods returns the distinct methods of the routes registered with
+// the router and its groups.
+func (r *Router) registeredMethods() []string {
+	root := r.root()
+	root.routesMu.Lock()
+	defer root.routesMu.Unlock()
+	methods := make([]string, 0, len(root.methods))
+	for method := range root.methods {
+		methods = append(methods, method)
+	}
+	return methods
 }
 
-// Handle registers a new endpoint to handle the given path and method.
-func (r *Router) Handle(method, path string, endpoint Endpoint) {
-	endpoint = applyMiddleware(endpoint, r)
-	handler := endpointToHandler(endpoint, path, r)
-	r.router.Handle(method, path, handler)
+// registeredRoutes returns the routes registered with the router and its
+// groups.
+func (r *Router) registeredRoutes() []*route {
+	root := r.root()
+	root.routesMu.Lock()
+	defer root.routesMu.Unlock()
+	return append([]*route(nil), root.routes...)
 }
 
 // ServeHTTP implements the http.Handler interface.
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	var handler http.Handler = r.router
-	if r.enableCompression {
-		handler = r.gzipHandler(handler)
-	}
-	handler.ServeHTTP(w, req)
+	r.router.ServeHTTP(w, req)
 }
 
 // applyMiddleware applies the routers's middleware to the provided endpoint.
+// The chain is composed on first use, and recomposed only if middleware has
+// since been added to the router or one of its parents.
 func applyMiddleware(e Endpoint, r *Router) Endpoint {
-	return func(ctx context.Context, req *Request) (interface{}, error) {
-		e, r := e, r // copy into local var in closure.
+	c := &middlewareChain{endpoint: e, router: r, root: r.root()}
+	return c.serve
+}
 
-		// Apply middleware from this router and all parent routers.
-		for {
-			for i := len(r.middleware) - 1; i >= 0; i-- {
-				e = r.middleware[i](e)
-			}
-			if r.parent == nil {
-				break
-			}
-			r = r.parent
+// middlewareChain is an endpoint composed with the middleware of a router and
+// all its parents.
+type middlewareChain struct {
+	endpoint Endpoint
+	router   *Router
+	root     *Router
+
+	// compiled holds the *compiledChain for the latest middleware version.
+	compiled atomic.Value
+}
+
+// compiledChain is a middleware chain composed at a middleware version.
+type compiledChain struct {
+	version  uint32
+	endpoint Endpoint
+}
+
+func (c *middlewareChain) serve(ctx context.Context, req *Request) (interface{}, error) {
+	version := atomic.LoadUint32(&c.root.middlewareVersion)
+	compiled, _ := c.compiled.Load().(*compiledChain)
+	if compiled == nil || compiled.version != version {
+		compiled = &compiledChain{version: version, endpoint: c.compose()}
+		c.compiled.Store(compiled)
+	}
+	return compiled.endpoint(ctx, req)
+}
+
+// compose applies the middleware from the router and all parent routers.
+func (c *middlewareChain) compose() Endpoint {
+	e := c.endpoint
+	for r := c.router; r != nil; r = r.parent {
+		for i := len(r.middleware) - 1; i >= 0; i-- {
+			e = r.middleware[i](e)
 		}
-		return e(ctx, req)
 	}
+	return e
 }
 
 // endpointToHandler converts an endpoint to an httprouter.Handle function.
 func endpointToHandler(e Endpoint, path string, router *Router) func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		ctx := req.Context()
+		jreq := &Request{
+			params:   params,
+			req:      req,
+			route:    path,
+			router:   router,
+			response: responseRecorder{ResponseWriter: w},
+		}
+		jreq.responseWriter = &jreq.response
+		w = jreq.responseWriter
+		defer jreq.finishResponse()
+
+		if len(router.codecs) > 0 {
+			w.Header().Add("Vary", "Accept")
+		}
+		// Requests which are not acceptable are rejected by negotiated, within
+		// the middleware chain, and their errors are rendered as JSON.
+		codec, ok := router.negotiateCodec(req.Header.Get("Accept"))
+		if !ok {
+			codec = router.jsonCodec()
+			jreq.notAcceptable = true
+		}
+
 		defer func() {
 			if r := recover(); r != nil {
-				log.Printf("panic serving %v: %+v", req.RequestURI, router)
-				debug.PrintStack()
-				router.sendJSON(w, 500, unknownError)
+				err := router.reportError(ctx, jreq, ErrorEvent{Panic: r, Stack: debug.Stack()})
+				router.sendError(w, jreq, codec, err)
 			}
 		}()
 
-		result, err := e(req.Context(), &Request{
-			params:         params,
-			req:            req,
-			responseWriter: w,
-			route:          path,
-		})
+		var result interface{}
+		var err error
+		if d, status := router.requestTimeout(req); d > 0 {
+			res := callWithTimeout(ctx, d, status, e, jreq, w)
+			if res.panic != nil {
+				err := router.reportError(ctx, jreq, ErrorEvent{Panic: res.panic, Stack: res.stack})
+				router.sendError(w, jreq, codec, err)
+				return
+			}
+			result, err = res.value, res.err
+		} else {
+			result, err = e(ctx, jreq)
+		}
 		if err != nil {
-			httpErr := translateError(err, router.DumpErrors)
-			router.sendJSON(w, httpErr.StatusCode(), httpErr)
+			if lookupError(err, router.errorMappers) == nil {
+				err = router.reportError(ctx, jreq, ErrorEvent{Err: err})
+			}
+			router.sendError(w, jreq, codec, err)
+			return
+		}
+		if _, ok := result.(responseWritten); ok {
 			return
 		}
 
+		status := 200
+		var header http.Header
 		if res, ok := result.(Response); ok {
-			router.sendJSON(w, res.StatusCode, res.Body)
-			return
+			status, result, header = res.StatusCode, res.Body, res.header()
 		}
+		if err := router.writeBody(w, status, codec, codec.ContentType(), result, header); err != nil {
+			err = router.reportError(ctx, jreq, ErrorEvent{Err: err})
+			router.sendError(w, jreq, codec, err)
+		}
+	}
+}
 
-		router.sendJSON(w, 200, result)
+// negotiated wraps e to reject requests for which none of the media types
+// accepted by the client are supported, and requests whose body has an
+// unsupported Content-Type. It is applied within the middleware chain, so that
+// middleware observes such requests.
+func negotiated(e Endpoint) Endpoint {
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		if req.notAcceptable {
+			return nil, errNotAcceptable
+		}
+		if hasBody(req.req) {
+			if err := req.router.checkContentType(req.req.Header.Get("Content-Type")); err != nil {
+				return nil, err
+			}
+		}
+		return e(ctx, req)
 	}
 }
 
-// sendJSON encodes v as JSON and writes it to the response body. Panics
-// if an encoding error occurs.
-func (r *Router) sendJSON(w http.ResponseWriter, status int, v interface{}) {
-	// TODO: Maybe don't panic? This will encounter an error if the caller
-	// closes the response early.
-	w.Header().Set("content-type", "application/json; charset=utf-8")
-	w.WriteHeader(status)
+// reportError notifies the error handler of an internal error or panic, and
+// returns the error to be rendered to the client.
+func (r *Router) reportError(ctx context.Context, req *Request, ev ErrorEvent) error {
+	if r.errorHandler != nil {
+		if err := r.errorHandler(ctx, req, ev); err != nil {
+			return err
+		}
+	} else if ev.Panic != nil {
+		log.Printf("panic serving %v: %v\n%s", req.req.RequestURI, ev.Panic, ev.Stack)
+	}
+
+	if ev.Err != nil {
+		return ev.Err
+	}
+	return fmt.Errorf("panic: %v", ev.Panic)
+}
+
+// sendError translates err into an HTTPErrorResponse and writes it to the
+// response body using codec.
+func (r *Router) sendError(w http.ResponseWriter, req *Request, codec Codec, err error) {
+	errResponse := translateError(err, r.errorMappers, r.DumpErrors)
+	observeError(req.req.Context(), err, errResponse)
+	if httpErr, ok := errResponse.(*HTTPError); ok {
+		req.errorCode = httpErr.Code
+	}
+	if id, inErrors := req.requestID(); inErrors {
+		if httpErr, ok := errResponse.(*HTTPError); ok {
+			withID := *httpErr
+			withID.RequestID = id
+			errResponse = &withID
+		}
+	}
+	if httpErr, ok := errResponse.(*HTTPError); ok && r.problemDetails {
+		errResponse = httpErr.ProblemDetails(req.req.URL.Path)
+	}
+
+	contentType := codec.ContentType()
+	if _, ok := errResponse.(*ProblemDetails); ok {
+		codec, contentType = r.jsonCodec(), ProblemDetailsContentType
+	}
+	if err := r.writeBody(w, errResponse.StatusCode(), codec, contentType, errResponse, nil); err != nil {
+		// The error itself could not be encoded, so fall back to the unknown
+		// error which always can be.
+		log.Printf("jsonrest: cannot encode error response: %v", err)
+		req.errorCode = unknownError.Code
+		jsonCodec := r.jsonCodec()
+		_ = r.writeBody(w, unknownError.Status, jsonCodec, jsonCodec.ContentType(), unknownError, nil)
+	}
+}
 
+// writeBody encodes v using codec and writes it to the response body with the
+// given content type and any additional header. The body is encoded into a
+// buffer before the header is written, so that if an encoding error occurs
+// nothing is written and the error is returned. Errors writing to w are
+// ignored, since they occur when the client closes the connection early.
+func (r *Router) writeBody(w http.ResponseWriter, status int, codec Codec, contentType string, v interface{}, header http.Header) error {
 	if v == nil {
+		addHeader(w.Header(), header)
+		w.Header().Set("content-type", contentType)
+		w.WriteHeader(status)
+		return nil
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	start := time.Now()
+	if err := codec.Encode(buf, v); err != nil {
+		return err
+	}
+	if rec, ok := w.(*responseRecorder); ok {
+		rec.encodeDuration += time.Since(start)
+	}
+
+	addHeader(w.Header(), header)
+	w.Header().Set("content-type", contentType)
+	w.Header().Set("content-length", strconv.Itoa(buf.Len()))
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
+	return nil
+}
+
+// addHeader adds the values of src to dst.
+func addHeader(dst, src http.Header) {
+	for k, vs := range src {
+		for _, v := range vs {
+			dst.Add(k, v)
+		}
+	}
+}
+
+// maxPooledBufferSize is the capacity above which buffers are not returned to
+// bufferPool, to avoid holding on to memory used by unusually large responses.
+const maxPooledBufferSize = 64 << 10
+
+// bufferPool holds buffers used to encode response bodies.
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets buf and returns it to the pool.
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
 		return
 	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// allowedHandler sets the Allow header to the methods of the routes matching
+// the request path. OPTIONS requests are answered with an empty HTTP 204 No
+// Content response, and any other requests are passed to next.
+func allowedHandler(r *Router, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodOptions {
+			r.serveOptions(w, req)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(r.allowedMethods(req.URL.Path), ", "))
+		next.ServeHTTP(w, req)
+	})
+}
+
+// serveOptions answers an OPTIONS request with an empty HTTP 204 No Content
+// response, with the Allow header set to the methods of the routes matching
+// the request path.
+func (r *Router) serveOptions(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Allow", strings.Join(r.allowedMethods(req.URL.Path), ", "))
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	enc := json.NewEncoder(w)
-	if !r.disableJSONIndent {
-		enc.SetIndent("", "  ")
+// allowedMethods returns the sorted methods of the routes matching path,
+// including OPTIONS.
+func (r *Router) allowedMethods(path string) []string {
+	methods := []string{http.MethodOptions}
+	for _, method := range r.registeredMethods() {
+		if method == http.MethodOptions {
+			continue
+		}
+		if h, _, _ := r.router.Lookup(method, path); h != nil {
+			methods = append(methods, method)
+		}
 	}
-	if err := enc.Encode(v); err != nil {
-		panic(err)
+	sort.Strings(methods)
+	return methods
+}
+
+// methodNotAllowedHandler returns a 405 method not allowed response to the
+// caller.
+func methodNotAllowedHandler(r *Router) http.Handler {
+	endpoint := func(_ context.Context, req *Request) (interface{}, error) {
+		msg := fmt.Sprintf("method %s not allowed", req.Method())
+		return nil, Error(405, "method_not_allowed", msg)
 	}
+	h := endpointToHandler(endpoint, "", r)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		h(w, req, nil)
+	})
 }
 
 // notFoundHandler returns a 404 not found response to the caller.
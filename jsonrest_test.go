@@ -31,6 +31,7 @@ func TestSimpleGet(t *testing.T) {
 
 	w := do(r, http.MethodGet, "/hello", nil, "application/json", nil)
 	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.Equal(t, w.Result().Header.Get("Content-Length"), strconv.Itoa(w.Body.Len()))
 	assert.JSONEqual(t, w.Body.String(), m{"message": "Hello World"})
 }
 
@@ -64,6 +65,50 @@ func TestCustomSuccessStatusCode(t *testing.T) {
 	assert.JSONEqual(t, w.Body.String(), `{"data":"byebye"}`)
 }
 
+func TestResponseHeaders(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		res := jsonrest.Created("/users/1", jsonrest.M{"id": 1})
+		res.Cookies = []*http.Cookie{{Name: "session", Value: "abc"}}
+		return res, nil
+	})
+	r.Get("/old", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Redirect(http.StatusSeeOther, "/new"), nil
+	})
+
+	w := do(r, http.MethodPost, "/users", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, http.StatusCreated)
+	assert.Equal(t, w.Result().Header.Get("Location"), "/users/1")
+	assert.Equal(t, w.Result().Header.Get("Set-Cookie"), "session=abc")
+	assert.JSONEqual(t, w.Body.String(), m{"id": 1})
+
+	w = do(r, http.MethodGet, "/old", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, http.StatusSeeOther)
+	assert.Equal(t, w.Result().Header.Get("Location"), "/new")
+	assert.Equal(t, w.Body.String(), "")
+
+	t.Run("encoding error", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Get("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			res := jsonrest.Created("/users/1", jsonrest.M{"invalid": func() {}})
+			res.Cookies = []*http.Cookie{{Name: "session", Value: "abc"}}
+			return res, nil
+		})
+
+		w := do(r, http.MethodGet, "/", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 500)
+		assert.Equal(t, w.Result().Header.Get("Location"), "")
+		assert.Equal(t, w.Result().Header.Get("Set-Cookie"), "")
+	})
+
+	t.Run("invalid redirect", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		jsonrest.Redirect(http.StatusOK, "/new")
+	})
+}
+
 func TestRequestBody(t *testing.T) {
 	r := jsonrest.NewRouter()
 	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
@@ -94,6 +139,141 @@ func TestRequestBody(t *testing.T) {
 	})
 }
 
+func TestCodecs(t *testing.T) {
+	type user struct {
+		ID int `json:"id" xml:"id"`
+	}
+	r := jsonrest.NewRouter(jsonrest.WithCodec(jsonrest.XMLCodec{}))
+	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var u user
+		if err := r.BindBody(&u); err != nil {
+			return nil, err
+		}
+		return u, nil
+	})
+	r.Get("/map", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{
+			"id":    1,
+			"tags":  []string{"a", "b"},
+			"owner": jsonrest.M{"name": "alice"},
+			"meta":  map[string]interface{}{"deleted": false},
+		}, nil
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", strings.NewReader(`<user><id>1</id></user>`), "application/xml", map[string]string{"Accept": "application/xml"})
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Result().Header.Get("Content-Type"), "application/xml; charset=utf-8")
+		assert.Equal(t, w.Result().Header.Get("Vary"), "Accept")
+		assert.Equal(t, w.Body.String(), "<user><id>1</id></user>")
+
+		w = do(r, http.MethodPost, "/users", strings.NewReader(`{"id": 1}`), "application/json", nil)
+		assert.Equal(t, w.Result().Header.Get("Vary"), "Accept")
+	})
+
+	t.Run("xml map", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/map", nil, "", map[string]string{"Accept": "application/xml"})
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Body.String(), "<M><id>1</id><meta><deleted>false</deleted></meta><owner><name>alice</name></owner><tags>a</tags><tags>b</tags></M>")
+	})
+
+	t.Run("json only", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Get("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return nil, nil
+		})
+		w := do(r, http.MethodGet, "/", nil, "", nil)
+		assert.Equal(t, w.Result().Header.Get("Vary"), "")
+	})
+
+	t.Run("xml error", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", strings.NewReader(`<user>`), "application/xml", map[string]string{"Accept": "application/xml"})
+		assert.Equal(t, w.Result().StatusCode, 400)
+		assert.Equal(t, w.Body.String(), "<error><code>bad_request</code><message>malformed or unexpected request body</message></error>")
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", strings.NewReader(`{"id": 1}`), "application/json", map[string]string{"Accept": "text/csv"})
+		assert.Equal(t, w.Result().StatusCode, 406)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "not_acceptable",
+				"message": "none of the accepted media types are supported",
+			},
+		})
+	})
+
+	t.Run("unsupported media type", func(t *testing.T) {
+		w := do(r, http.MethodPost, "/users", strings.NewReader(`id=1`), "text/plain", nil)
+		assert.Equal(t, w.Result().StatusCode, 415)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unsupported_media_type",
+				"message": `unsupported content type "text/plain"`,
+			},
+		})
+	})
+}
+
+func TestContentType(t *testing.T) {
+	r := jsonrest.NewRouter()
+	r.Post("/json", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var params map[string]interface{}
+		return params, r.BindBody(&params)
+	})
+	r.Post("/empty", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+	g := r.Group(jsonrest.WithContentTypes("text/*"))
+	g.Post("/text", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+
+	tests := []struct {
+		path        string
+		body        io.Reader
+		contentType string
+		wantStatus  int
+	}{
+		{"/json", strings.NewReader(`{}`), "application/json", 200},
+		{"/json", strings.NewReader(`{}`), "application/json; charset=UTF-8", 200},
+		{"/json", strings.NewReader(`{}`), "application/merge-patch+json", 200},
+		{"/json", strings.NewReader(`{}`), "", 200},
+		{"/json", strings.NewReader(`{}`), "application/json; charset=latin1", 415},
+		{"/json", strings.NewReader(`a`), "text/plain", 415},
+		{"/empty", strings.NewReader(`a=b`), "application/x-www-form-urlencoded", 200},
+		{"/empty", nil, "text/plain", 200},
+		{"/text", strings.NewReader(`hello`), "text/plain", 200},
+		{"/text", strings.NewReader(`{}`), "application/json", 415},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.contentType, func(t *testing.T) {
+			w := do(r, http.MethodPost, tt.path, tt.body, tt.contentType, nil)
+			assert.Equal(t, w.Result().StatusCode, tt.wantStatus)
+		})
+	}
+
+	t.Run("middleware", func(t *testing.T) {
+		var statuses []int
+		r := jsonrest.NewRouter()
+		r.Use(func(next jsonrest.Endpoint) jsonrest.Endpoint {
+			return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+				req.OnResponse(func(res jsonrest.ResponseInfo) {
+					statuses = append(statuses, res.Status)
+				})
+				return next(ctx, req)
+			}
+		})
+		r.Post("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return nil, nil
+		})
+
+		do(r, http.MethodPost, "/", strings.NewReader(`a`), "text/plain", nil)
+		do(r, http.MethodPost, "/", nil, "", map[string]string{"Accept": "text/csv"})
+		assert.Equal(t, statuses, []int{415, 406})
+	})
+}
+
 func TestFormFile(t *testing.T) {
 	const defaultMaxMemory = 32 << 20
 	r := jsonrest.NewRouter()
@@ -172,6 +352,59 @@ func TestNotFound(t *testing.T) {
 	})
 }
 
+func TestMethodNotAllowed(t *testing.T) {
+	noop := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) { return nil, nil }
+
+	t.Run("no override", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Post("/users", noop)
+		r.Get("/users", noop)
+		r.Get("/users/:id", noop)
+
+		w := do(r, http.MethodDelete, "/users", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 405)
+		assert.Equal(t, w.Result().Header.Get("Allow"), "GET, OPTIONS, POST")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "method_not_allowed",
+				"message": "method DELETE not allowed",
+			},
+		})
+
+		w = do(r, http.MethodOptions, "/users/1", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 204)
+		assert.Equal(t, w.Result().Header.Get("Allow"), "GET, OPTIONS")
+		assert.Equal(t, w.Body.Len(), 0)
+
+		w = do(r, http.MethodOptions, "/invalid_path", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 404)
+	})
+
+	t.Run("explicit OPTIONS route", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Get("/users", noop)
+		r.Handle(http.MethodOptions, "/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return jsonrest.M{"custom": true}, nil
+		})
+
+		w := do(r, http.MethodOptions, "/users", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.JSONEqual(t, w.Body.String(), m{"custom": true})
+	})
+
+	t.Run("with override", func(t *testing.T) {
+		h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		r := jsonrest.NewRouter(jsonrest.WithMethodNotAllowedHandler(h))
+		r.Get("/users", noop)
+
+		w := do(r, http.MethodPut, "/users", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, http.StatusTeapot)
+		assert.Equal(t, w.Result().Header.Get("Allow"), "GET, OPTIONS")
+	})
+}
+
 type testError struct {
 	Message string `json:"message"`
 	status  int
@@ -213,6 +446,15 @@ func TestError(t *testing.T) {
 			&testError{Message: "test", status: 444},
 			444, m{"message": "test"},
 		},
+		{
+			fmt.Errorf("finding customer: %w", jsonrest.NotFound("customer not found")),
+			404, m{
+				"error": m{
+					"code":    "not_found",
+					"message": "customer not found",
+				},
+			},
+		},
 	}
 
 	for i, tt := range tests {
@@ -229,6 +471,111 @@ func TestError(t *testing.T) {
 	}
 }
 
+func TestErrorMapper(t *testing.T) {
+	errConflict := errors.New("conflict")
+	r := jsonrest.NewRouter(
+		jsonrest.WithErrorMapper(jsonrest.MapError(errConflict, jsonrest.Error(409, "conflict", "already exists"))),
+	)
+	r.Get("/conflict", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, fmt.Errorf("creating user: %w", errConflict)
+	})
+	r.Get("/other", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("other")
+	})
+
+	g := r.Group(jsonrest.WithErrorMapper(func(err error) jsonrest.HTTPErrorResponse {
+		return jsonrest.BadRequest(err.Error())
+	}))
+	g.Get("/group", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("other")
+	})
+
+	w := do(r, http.MethodGet, "/conflict", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 409)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "conflict",
+			"message": "already exists",
+		},
+	})
+
+	w = do(r, http.MethodGet, "/other", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 500)
+
+	w = do(r, http.MethodGet, "/group", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 400)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "bad_request",
+			"message": "other",
+		},
+	})
+}
+
+func TestErrorHandler(t *testing.T) {
+	var events []jsonrest.ErrorEvent
+	r := jsonrest.NewRouter(jsonrest.WithErrorHandler(func(ctx context.Context, req *jsonrest.Request, ev jsonrest.ErrorEvent) error {
+		events = append(events, ev)
+		if ev.Panic != nil {
+			return jsonrest.Error(503, "unavailable", "try again later")
+		}
+		return nil
+	}))
+	r.Get("/panic", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+	r.Get("/internal", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("internal")
+	})
+	r.Get("/http", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("not here")
+	})
+
+	w := do(r, http.MethodGet, "/panic", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 503)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unavailable",
+			"message": "try again later",
+		},
+	})
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Panic, "boom")
+	assert.True(t, len(events[0].Stack) > 0)
+
+	w = do(r, http.MethodGet, "/internal", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 500)
+	assert.Equal(t, len(events), 2)
+	assert.Equal(t, events[1].Err.Error(), "internal")
+
+	w = do(r, http.MethodGet, "/http", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 404)
+	assert.Equal(t, len(events), 2)
+}
+
+func TestEncodingError(t *testing.T) {
+	var events []jsonrest.ErrorEvent
+	r := jsonrest.NewRouter(jsonrest.WithErrorHandler(func(ctx context.Context, req *jsonrest.Request, ev jsonrest.ErrorEvent) error {
+		events = append(events, ev)
+		return nil
+	}))
+	r.Get("/chan", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"ch": make(chan int)}, nil
+	})
+
+	w := do(r, http.MethodGet, "/chan", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 500)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unknown_error",
+			"message": "an unknown error occurred",
+		},
+	})
+	assert.Equal(t, w.Result().Header.Get("Content-Length"), strconv.Itoa(w.Body.Len()))
+	assert.Equal(t, len(events), 1)
+	assert.Equal(t, events[0].Err.Error(), "json: unsupported type: chan int")
+}
+
 func TestDumpInternalError(t *testing.T) {
 	r := jsonrest.NewRouter()
 	r.DumpErrors = true
@@ -249,6 +596,85 @@ func TestDumpInternalError(t *testing.T) {
 	})
 }
 
+func TestProblemDetails(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithProblemDetails())
+	r.Get("/fail", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		err := jsonrest.Error(409, "conflict", "user already exists")
+		err.Type = "https://example.com/problems/conflict"
+		err.Extensions = map[string]interface{}{"user_id": 1}
+		return nil, err
+	})
+	r.Get("/unknown", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	r.Post("/users", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var params struct{}
+		return nil, r.BindBody(&params)
+	})
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       io.Reader
+		wantStatus int
+		want       interface{}
+	}{
+		{
+			"http error", http.MethodGet, "/fail", nil,
+			409, m{
+				"type":     "https://example.com/problems/conflict",
+				"title":    "Conflict",
+				"status":   409,
+				"detail":   "user already exists",
+				"instance": "/fail",
+				"code":     "conflict",
+				"user_id":  1,
+			},
+		},
+		{
+			"unknown error", http.MethodGet, "/unknown", nil,
+			500, m{
+				"type":     "about:blank",
+				"title":    "Internal Server Error",
+				"status":   500,
+				"detail":   "an unknown error occurred",
+				"instance": "/unknown",
+				"code":     "unknown_error",
+			},
+		},
+		{
+			"not found", http.MethodGet, "/missing", nil,
+			404, m{
+				"type":     "about:blank",
+				"title":    "Not Found",
+				"status":   404,
+				"detail":   "url not found",
+				"instance": "/missing",
+				"code":     "not_found",
+			},
+		},
+		{
+			"bind body", http.MethodPost, "/users", strings.NewReader(`{"id": |1}`), 400, m{
+				"type":     "about:blank",
+				"title":    "Bad Request",
+				"status":   400,
+				"detail":   "malformed or unexpected json: offset 8: invalid character '|' looking for beginning of value",
+				"instance": "/users",
+				"code":     "bad_request",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(r, tt.method, tt.path, tt.body, "application/json", nil)
+			assert.Equal(t, w.Result().StatusCode, tt.wantStatus)
+			assert.Equal(t, w.Result().Header.Get("Content-Type"), "application/problem+json")
+			assert.JSONEqual(t, w.Body.String(), tt.want)
+		})
+	}
+}
+
 func TestMiddleware(t *testing.T) {
 	t.Run("top level middleware", func(t *testing.T) {
 		r := jsonrest.NewRouter()
@@ -290,6 +716,106 @@ func TestMiddleware(t *testing.T) {
 		assert.Equal(t, w.Result().StatusCode, 200)
 		assert.False(t, called)
 	})
+	t.Run("added after routes", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		var calls []string
+		record := func(name string) jsonrest.Middleware {
+			return func(next jsonrest.Endpoint) jsonrest.Endpoint {
+				return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+					calls = append(calls, name)
+					return next(ctx, req)
+				}
+			}
+		}
+
+		g := r.Group()
+		g.Use(record("group"))
+		g.Get("/test", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil })
+
+		do(r, http.MethodGet, "/test", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"group"})
+
+		calls = nil
+		r.Use(record("root"))
+		g.Use(record("group2"))
+		do(r, http.MethodGet, "/test", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"root", "group", "group2"})
+	})
+	t.Run("route", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		var calls []string
+		record := func(name string) jsonrest.Middleware {
+			return func(next jsonrest.Endpoint) jsonrest.Endpoint {
+				return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+					calls = append(calls, name)
+					return next(ctx, req)
+				}
+			}
+		}
+		noop := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil }
+
+		r.Use(record("router"))
+		r.Get("/with", noop, record("route1"), record("route2"))
+		r.Get("/without", noop)
+		r.Routes(jsonrest.RouteMap{
+			"GET /mapped":  jsonrest.Route(noop, record("mapped")),
+			"GET /mapped2": jsonrest.Route(noop, record("mapped2")),
+		}, record("all"))
+
+		do(r, http.MethodGet, "/with", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"router", "route1", "route2"})
+
+		calls = nil
+		do(r, http.MethodGet, "/without", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"router"})
+
+		calls = nil
+		do(r, http.MethodGet, "/mapped", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"router", "mapped", "all"})
+
+		calls = nil
+		do(r, http.MethodGet, "/mapped2", nil, "application/json", nil)
+		assert.Equal(t, calls, []string{"router", "mapped2", "all"})
+	})
+	t.Run("router options", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		noop := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil }
+		for _, opt := range []jsonrest.Option{
+			jsonrest.WithNotFoundHandler(http.NotFoundHandler()),
+			jsonrest.WithCORS(jsonrest.CORSPolicy{AllowedOrigins: []string{"https://example.com"}}),
+			jsonrest.WithMetrics(jsonrest.NewMetrics()),
+		} {
+			func() {
+				defer func() {
+					assert.True(t, recover() != nil)
+				}()
+				r.Get("/test", noop, opt)
+			}()
+		}
+	})
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	passthrough := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			return next(ctx, req)
+		}
+	}
+
+	r := jsonrest.NewRouter()
+	g := r
+	for i := 0; i < 10; i++ {
+		g = g.Group()
+		g.Use(passthrough, passthrough)
+	}
+	g.Get("/test", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) { return nil, nil })
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
 }
 
 func TestOptions(t *testing.T) {
@@ -388,6 +914,41 @@ func TestOptions(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, string(body), fmt.Sprintf("{\n  \"message\": \"%s\"\n}\n", msg))
 	})
+	t.Run("route options", func(t *testing.T) {
+		msg := strings.Repeat("H", gziphandler.DefaultMinSize)
+		hello := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return jsonrest.M{"message": msg}, nil
+		}
+		r := jsonrest.NewRouter(jsonrest.WithCompressionEnabled(gzip.DefaultCompression))
+		r.Get("/compressed", hello)
+		r.Get("/export", hello, jsonrest.WithCompressionDisabled(), jsonrest.WithDisableJSONIndent())
+		r.Routes(jsonrest.RouteMap{
+			"GET /mapped": jsonrest.Route(hello, jsonrest.WithCompressionDisabled()),
+		})
+
+		gzipHeaders := map[string]string{"Accept-Encoding": "gzip"}
+		w := do(r, http.MethodGet, "/compressed", nil, "application/json", gzipHeaders)
+		assert.Equal(t, w.Result().Header.Get("Content-Encoding"), "gzip")
+
+		w = do(r, http.MethodGet, "/export", nil, "application/json", gzipHeaders)
+		assert.Equal(t, w.Result().Header.Get("Content-Encoding"), "")
+		assert.Equal(t, w.Body.String(), fmt.Sprintf("{\"message\":\"%s\"}\n", msg))
+
+		w = do(r, http.MethodGet, "/mapped", nil, "application/json", gzipHeaders)
+		assert.Equal(t, w.Result().Header.Get("Content-Encoding"), "")
+		assert.Equal(t, w.Body.String(), fmt.Sprintf("{\n  \"message\": \"%s\"\n}\n", msg))
+	})
+	t.Run("params", func(t *testing.T) {
+		r := jsonrest.NewRouter(jsonrest.WithCompressionEnabled(gzip.DefaultCompression), jsonrest.WithDisableJSONIndent())
+		r.Get("/users/:id", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			return jsonrest.M{"id": r.Param("id")}, nil
+		})
+
+		w := do(r, http.MethodGet, "/users/1", nil, "application/json", nil)
+		assert.Equal(t, w.Body.String(), "{\"id\":\"1\"}\n")
+		w = do(r, http.MethodGet, "/users/2", nil, "application/json", nil)
+		assert.Equal(t, w.Body.String(), "{\"id\":\"2\"}\n")
+	})
 }
 
 type m map[string]interface{}
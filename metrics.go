@@ -0,0 +1,268 @@
+package jsonrest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLatencyBuckets are the default upper bounds, in seconds, of the
+// buckets of the request duration histogram.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the default upper bounds, in bytes, of the buckets of
+// the response size histogram.
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1e6, 1e7}
+
+// Metrics records metrics about the requests to the routes of routers
+// configured with WithMetrics, and serves them in the Prometheus text
+// exposition format:
+//
+//	http_requests_total                  counter of requests
+//	http_requests_in_flight              gauge of requests being served
+//	http_request_duration_seconds        histogram of request durations
+//	http_response_size_bytes             histogram of response body sizes
+//
+// Requests are labelled by method, route pattern (see Request.Route), status
+// code and error code, which is empty for successful requests. In-flight
+// requests are labelled by method and route only. Requests which do not match
+// a route are not recorded.
+//
+// For example:
+//
+//	m := jsonrest.NewMetrics()
+//	r := jsonrest.NewRouter(jsonrest.WithMetrics(m))
+//	r.HandleHTTP(http.MethodGet, "/metrics", m)
+type Metrics struct {
+	namespace      string
+	latencyBuckets []float64
+	sizeBuckets    []float64
+
+	mu       sync.Mutex
+	requests map[requestLabels]*requestSeries
+	inFlight map[routeLabels]int64
+}
+
+// A MetricsOption configures Metrics.
+type MetricsOption func(*Metrics)
+
+// MetricsNamespace is a MetricsOption which prefixes the metric names with
+// namespace and an underscore.
+func MetricsNamespace(namespace string) MetricsOption {
+	return func(m *Metrics) {
+		m.namespace = namespace
+	}
+}
+
+// LatencyBuckets is a MetricsOption which sets the upper bounds, in seconds,
+// of the buckets of the request duration histogram.
+func LatencyBuckets(buckets ...float64) MetricsOption {
+	return func(m *Metrics) {
+		m.latencyBuckets = append([]float64(nil), buckets...)
+	}
+}
+
+// SizeBuckets is a MetricsOption which sets the upper bounds, in bytes, of
+// the buckets of the response size histogram.
+func SizeBuckets(buckets ...float64) MetricsOption {
+	return func(m *Metrics) {
+		m.sizeBuckets = append([]float64(nil), buckets...)
+	}
+}
+
+// NewMetrics returns a new Metrics.
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{
+		latencyBuckets: DefaultLatencyBuckets,
+		sizeBuckets:    DefaultSizeBuckets,
+		requests:       make(map[requestLabels]*requestSeries),
+		inFlight:       make(map[routeLabels]int64),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	sort.Float64s(m.latencyBuckets)
+	sort.Float64s(m.sizeBuckets)
+	return m
+}
+
+// WithMetrics is an Option which records metrics about the requests to the
+// routes of the router and its groups in m.
+func WithMetrics(m *Metrics) Option {
+	return routerOption("WithMetrics", func(r *Router) {
+		r.metrics = m
+	})
+}
+
+// routeLabels are the labels of in-flight requests.
+type routeLabels struct {
+	method string
+	route  string
+}
+
+// requestLabels are the labels of completed requests.
+type requestLabels struct {
+	routeLabels
+	status int
+	code   string
+}
+
+// requestSeries are the metrics of requests with the same labels.
+type requestSeries struct {
+	count   uint64
+	latency histogram
+	size    histogram
+}
+
+// histogram is a cumulative histogram.
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// observe records v in the histogram with the given bucket bounds.
+func (h *histogram) observe(buckets []float64, v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(buckets))
+	}
+	for i, bound := range buckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// begin records the start of a request.
+func (m *Metrics) begin(labels routeLabels) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[labels]++
+}
+
+// observe records a completed request.
+func (m *Metrics) observe(labels requestLabels, latency time.Duration, size int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[labels.routeLabels]--
+
+	s, ok := m.requests[labels]
+	if !ok {
+		s = &requestSeries{}
+		m.requests[labels] = s
+	}
+	s.count++
+	s.latency.observe(m.latencyBuckets, latency.Seconds())
+	s.size.observe(m.sizeBuckets, float64(size))
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	m.write(bw)
+	_ = bw.Flush()
+}
+
+// write writes the metrics to w in the Prometheus text exposition format.
+func (m *Metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	requests := make([]requestLabels, 0, len(m.requests))
+	for labels := range m.requests {
+		requests = append(requests, labels)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].String() < requests[j].String()
+	})
+	inFlight := make([]routeLabels, 0, len(m.inFlight))
+	for labels := range m.inFlight {
+		inFlight = append(inFlight, labels)
+	}
+	sort.Slice(inFlight, func(i, j int) bool {
+		return inFlight[i].String() < inFlight[j].String()
+	})
+
+	name := m.name("http_requests_total")
+	writeMetricHeader(w, name, "counter", "Total number of HTTP requests.")
+	for _, labels := range requests {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, labels, m.requests[labels].count)
+	}
+
+	name = m.name("http_requests_in_flight")
+	writeMetricHeader(w, name, "gauge", "Number of HTTP requests being served.")
+	for _, labels := range inFlight {
+		fmt.Fprintf(w, "%s{%s} %d\n", name, labels, m.inFlight[labels])
+	}
+
+	name = m.name("http_request_duration_seconds")
+	writeMetricHeader(w, name, "histogram", "Duration of HTTP requests in seconds.")
+	for _, labels := range requests {
+		writeHistogram(w, name, labels.String(), m.latencyBuckets, &m.requests[labels].latency)
+	}
+
+	name = m.name("http_response_size_bytes")
+	writeMetricHeader(w, name, "histogram", "Size of HTTP response bodies in bytes.")
+	for _, labels := range requests {
+		writeHistogram(w, name, labels.String(), m.sizeBuckets, &m.requests[labels].size)
+	}
+}
+
+// name returns the name of the metric, prefixed with the namespace.
+func (m *Metrics) name(name string) string {
+	if m.namespace == "" {
+		return name
+	}
+	return m.namespace + "_" + name
+}
+
+// String returns the labels in the exposition format.
+func (l routeLabels) String() string {
+	return fmt.Sprintf(`method="%s",route="%s"`, escapeLabel(l.method), escapeLabel(l.route))
+}
+
+// String returns the labels in the exposition format.
+func (l requestLabels) String() string {
+	return fmt.Sprintf(`%s,status="%d",code="%s"`, l.routeLabels, l.status, escapeLabel(l.code))
+}
+
+// writeMetricHeader writes the HELP and TYPE lines of a metric.
+func writeMetricHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// writeHistogram writes the samples of a histogram.
+func writeHistogram(w io.Writer, name, labels string, buckets []float64, h *histogram) {
+	for i, bound := range buckets {
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatFloat(bound), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, h.count)
+	fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, h.count)
+}
+
+// formatFloat formats v in the exposition format.
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values in the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value.
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,71 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestMetrics(t *testing.T) {
+	m := jsonrest.NewMetrics(jsonrest.MetricsNamespace("app"), jsonrest.LatencyBuckets(10), jsonrest.SizeBuckets(1, 1000))
+	r := jsonrest.NewRouter(jsonrest.WithMetrics(m), jsonrest.WithDisableJSONIndent())
+	r.Get("/users/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		if req.Param("id") == "0" {
+			return nil, jsonrest.NotFound("no such user")
+		}
+		return jsonrest.M{"id": req.Param("id")}, nil
+	})
+	r.Group().HandleHTTP(http.MethodGet, "/metrics", m)
+
+	do(r, http.MethodGet, "/users/1", nil, "application/json", nil)
+	do(r, http.MethodGet, "/users/2", nil, "application/json", nil)
+	do(r, http.MethodGet, "/users/0", nil, "application/json", nil)
+	do(r, http.MethodGet, "/unknown", nil, "application/json", nil)
+
+	w := do(r, http.MethodGet, "/metrics", nil, "", nil)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.Equal(t, w.Result().Header.Get("Content-Type"), "text/plain; version=0.0.4; charset=utf-8")
+
+	// The duration sums vary, so are not compared.
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		if !strings.HasPrefix(line, "app_http_request_duration_seconds_sum") {
+			lines = append(lines, line)
+		}
+	}
+	assert.Equal(t, lines, []string{
+		`# HELP app_http_requests_total Total number of HTTP requests.`,
+		`# TYPE app_http_requests_total counter`,
+		`app_http_requests_total{method="GET",route="/users/:id",status="200",code=""} 2`,
+		`app_http_requests_total{method="GET",route="/users/:id",status="404",code="not_found"} 1`,
+		`# HELP app_http_requests_in_flight Number of HTTP requests being served.`,
+		`# TYPE app_http_requests_in_flight gauge`,
+		`app_http_requests_in_flight{method="GET",route="/metrics"} 1`,
+		`app_http_requests_in_flight{method="GET",route="/users/:id"} 0`,
+		`# HELP app_http_request_duration_seconds Duration of HTTP requests in seconds.`,
+		`# TYPE app_http_request_duration_seconds histogram`,
+		`app_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",code="",le="10"} 2`,
+		`app_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",code="",le="+Inf"} 2`,
+		`app_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200",code=""} 2`,
+		`app_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="404",code="not_found",le="10"} 1`,
+		`app_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="404",code="not_found",le="+Inf"} 1`,
+		`app_http_request_duration_seconds_count{method="GET",route="/users/:id",status="404",code="not_found"} 1`,
+		`# HELP app_http_response_size_bytes Size of HTTP response bodies in bytes.`,
+		`# TYPE app_http_response_size_bytes histogram`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="200",code="",le="1"} 0`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="200",code="",le="1000"} 2`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="200",code="",le="+Inf"} 2`,
+		`app_http_response_size_bytes_sum{method="GET",route="/users/:id",status="200",code=""} 22`,
+		`app_http_response_size_bytes_count{method="GET",route="/users/:id",status="200",code=""} 2`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="404",code="not_found",le="1"} 0`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="404",code="not_found",le="1000"} 1`,
+		`app_http_response_size_bytes_bucket{method="GET",route="/users/:id",status="404",code="not_found",le="+Inf"} 1`,
+		`app_http_response_size_bytes_sum{method="GET",route="/users/:id",status="404",code="not_found"} 56`,
+		`app_http_response_size_bytes_count{method="GET",route="/users/:id",status="404",code="not_found"} 1`,
+	})
+}
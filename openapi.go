@@ -0,0 +1,727 @@
+package jsonrest
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIDocument is an OpenAPI 3 document describing the routes of a
+// Router. Only the subset of the specification used by jsonrest is modelled.
+type OpenAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Paths      map[string]OpenAPIPathItem `json:"paths"`
+	Components *OpenAPIComponents         `json:"components,omitempty"`
+}
+
+// OpenAPIInfo provides metadata about the API.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// OpenAPIPathItem maps lowercase HTTP methods to the operations available on
+// a path.
+type OpenAPIPathItem map[string]*OpenAPIOperation
+
+// UnmarshalJSON implements the json.Unmarshaler interface. Parameters declared
+// for the whole path are added to each operation which does not override
+// them, and other path-level fields are ignored.
+func (item *OpenAPIPathItem) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var shared []*OpenAPIParameter
+	if params, ok := raw["parameters"]; ok {
+		if err := json.Unmarshal(params, &shared); err != nil {
+			return err
+		}
+	}
+
+	*item = make(OpenAPIPathItem)
+	for key, value := range raw {
+		switch key {
+		case "get", "put", "post", "delete", "options", "head", "patch", "trace":
+		default:
+			continue
+		}
+		var op OpenAPIOperation
+		if err := json.Unmarshal(value, &op); err != nil {
+			return err
+		}
+	shared:
+		for _, p := range shared {
+			for _, q := range op.Parameters {
+				if p.Name == q.Name && p.In == q.In {
+					continue shared
+				}
+			}
+			op.Parameters = append(op.Parameters, p)
+		}
+		(*item)[key] = &op
+	}
+	return nil
+}
+
+// OpenAPIOperation describes a single API operation on a path.
+type OpenAPIOperation struct {
+	Summary     string                      `json:"summary,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+}
+
+// OpenAPIParameter describes a single path, query or header parameter.
+type OpenAPIParameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema,omitempty"`
+}
+
+// OpenAPIRequestBody describes a request body.
+type OpenAPIRequestBody struct {
+	Required bool                         `json:"required,omitempty"`
+	Content  map[string]*OpenAPIMediaType `json:"content"`
+}
+
+// OpenAPIResponse describes a single response from an operation.
+type OpenAPIResponse struct {
+	Description string                       `json:"description"`
+	Content     map[string]*OpenAPIMediaType `json:"content,omitempty"`
+}
+
+// OpenAPIMediaType describes the body of a particular media type.
+type OpenAPIMediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// OpenAPIComponents holds reusable schemas.
+type OpenAPIComponents struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// Schema is a JSON Schema, as used by OpenAPI 3.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+
+	// NoAdditionalProperties is true if additionalProperties is false, i.e.
+	// objects may not have properties other than those listed.
+	NoAdditionalProperties bool `json:"-"`
+}
+
+// schemaJSON is the JSON representation of a Schema, in which
+// additionalProperties may be a boolean.
+type schemaJSON struct {
+	*schemaFields
+	AdditionalProperties json.RawMessage `json:"additionalProperties,omitempty"`
+}
+
+type schemaFields Schema
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	raw := schemaJSON{schemaFields: (*schemaFields)(s)}
+	switch {
+	case s.NoAdditionalProperties:
+		raw.AdditionalProperties = json.RawMessage("false")
+	case s.AdditionalProperties != nil:
+		b, err := json.Marshal(s.AdditionalProperties)
+		if err != nil {
+			return nil, err
+		}
+		raw.AdditionalProperties = b
+	}
+	return json.Marshal(raw)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	raw := schemaJSON{schemaFields: (*schemaFields)(s)}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch string(raw.AdditionalProperties) {
+	case "", "true":
+		s.AdditionalProperties = nil
+	case "false":
+		s.AdditionalProperties, s.NoAdditionalProperties = nil, true
+	default:
+		return json.Unmarshal(raw.AdditionalProperties, &s.AdditionalProperties)
+	}
+	return nil
+}
+
+// Summary is a RouteOption documenting the route with a short summary.
+func Summary(summary string) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.summary = summary
+	})
+}
+
+// Description is a RouteOption documenting the route with a longer
+// description.
+func Description(description string) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.description = description
+	})
+}
+
+// Tags is a RouteOption grouping the route under the given tags.
+func Tags(tags ...string) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.tags = append(rt.tags, tags...)
+	})
+}
+
+// RequestType is a RouteOption documenting the type bound by the endpoint,
+// given as a value of that type. Fields with "query", "param" or "header" tags
+// are documented as parameters, and any other fields as the request body.
+func RequestType(v interface{}) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.requestType = reflect.TypeOf(v)
+	})
+}
+
+// ResponseType is a RouteOption documenting the success status code and the
+// type of the response body, given as a value of that type.
+func ResponseType(status int, v interface{}) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.status = status
+		rt.responseType = reflect.TypeOf(v)
+	})
+}
+
+// Errors is a RouteOption documenting the errors that may be returned by the
+// endpoint.
+func Errors(errs ...*HTTPError) RouteOption {
+	return routeOptionFunc(func(rt *route) {
+		rt.errors = append(rt.errors, errs...)
+	})
+}
+
+// WithOpenAPIInfo is an Option available for NewRouter to describe the API in
+// the documents generated by Router.OpenAPI.
+func WithOpenAPIInfo(info OpenAPIInfo) Option {
+	return routerOption("WithOpenAPIInfo", func(r *Router) {
+		r.openAPIInfo = info
+	})
+}
+
+// ServeOpenAPI registers a GET endpoint at path serving the OpenAPI document
+// of the router. The document is generated on each request, so it includes
+// routes registered after ServeOpenAPI is called.
+func (r *Router) ServeOpenAPI(path string) {
+	r.Handle(http.MethodGet, path, func(context.Context, *Request) (interface{}, error) {
+		return r.OpenAPI(), nil
+	}, routeOptionFunc(func(rt *route) {
+		rt.hidden = true
+	}))
+}
+
+// OpenAPI generates an OpenAPI 3 document describing the routes registered
+// with the router and its groups. Request and response schemas are reflected
+// from the types documented by the RequestType and ResponseType route
+// options, including the rules of any "validate" struct tags.
+func (r *Router) OpenAPI() *OpenAPIDocument {
+	root := r.root()
+	info := root.openAPIInfo
+	if info.Title == "" {
+		info.Title = "API"
+	}
+	if info.Version == "" {
+		info.Version = "1.0.0"
+	}
+
+	doc := &OpenAPIDocument{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   make(map[string]OpenAPIPathItem),
+	}
+	g := &schemaGenerator{
+		schemas: make(map[string]*Schema),
+		names:   make(map[reflect.Type]string),
+	}
+	for _, rt := range root.registeredRoutes() {
+		if rt.hidden {
+			continue
+		}
+		path, params := openAPIPath(rt.path)
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(OpenAPIPathItem)
+			doc.Paths[path] = item
+		}
+		item[strings.ToLower(rt.method)] = g.operation(rt, params)
+	}
+	if len(g.schemas) > 0 {
+		doc.Components = &OpenAPIComponents{Schemas: g.schemas}
+	}
+	return doc
+}
+
+// openAPIPath converts an httprouter path pattern to an OpenAPI path template,
+// returning the names of its parameters.
+func openAPIPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if part != "" && (part[0] == ':' || part[0] == '*') {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// schemaGenerator reflects Go types into schemas, collecting the schemas of
+// named struct types as reusable components.
+type schemaGenerator struct {
+	schemas map[string]*Schema
+
+	// names are the component names of named struct types.
+	names map[reflect.Type]string
+}
+
+// errorComponents are the component names of the error schemas, which are
+// reserved.
+var errorComponents = map[string]bool{"Error": true, "ProblemDetails": true}
+
+// componentName returns the component name of the named struct type t. Types
+// are named after their Go name, qualified by their package name if it is
+// already used by another type, and made unique with a numeric suffix if
+// necessary.
+func (g *schemaGenerator) componentName(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+	taken := func(name string) bool {
+		_, ok := g.schemas[name]
+		return ok || errorComponents[name]
+	}
+	name := t.Name()
+	if taken(name) {
+		name = path.Base(t.PkgPath()) + "." + t.Name()
+	}
+	for i := 2; taken(name); i++ {
+		name = path.Base(t.PkgPath()) + "." + t.Name() + strconv.Itoa(i)
+	}
+	g.names[t] = name
+	return name
+}
+
+// operation documents the route.
+func (g *schemaGenerator) operation(rt *route, pathParams []string) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Summary:     rt.summary,
+		Description: rt.description,
+		Tags:        rt.tags,
+		Responses:   make(map[string]*OpenAPIResponse),
+	}
+
+	documented := make(map[string]bool)
+	if rt.requestType != nil {
+		for _, p := range g.parameters(rt.requestType) {
+			documented[p.In+":"+p.Name] = true
+			op.Parameters = append(op.Parameters, p)
+		}
+		if body := g.bodySchema(rt.requestType); body != nil {
+			op.RequestBody = &OpenAPIRequestBody{
+				Required: true,
+				Content:  map[string]*OpenAPIMediaType{"application/json": {Schema: body}},
+			}
+		}
+	}
+	for _, name := range pathParams {
+		if !documented["path:"+name] {
+			op.Parameters = append(op.Parameters, &OpenAPIParameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: "string"},
+			})
+		}
+	}
+
+	status := rt.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	res := &OpenAPIResponse{Description: http.StatusText(status)}
+	if rt.responseType != nil {
+		res.Content = map[string]*OpenAPIMediaType{"application/json": {Schema: g.schema(rt.responseType)}}
+	}
+	op.Responses[strconv.Itoa(status)] = res
+
+	for _, err := range rt.errors {
+		key := strconv.Itoa(err.Status)
+		if res, ok := op.Responses[key]; ok {
+			res.Description += "; " + err.Message
+			continue
+		}
+		op.Responses[key] = &OpenAPIResponse{
+			Description: err.Message,
+			Content:     map[string]*OpenAPIMediaType{errorMediaType(rt.router): {Schema: g.errorSchema(rt.router)}},
+		}
+	}
+	return op
+}
+
+// parameters documents the fields of the struct type t with "param", "query"
+// or "header" tags as parameters.
+func (g *schemaGenerator) parameters(t reflect.Type) []*OpenAPIParameter {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var params []*OpenAPIParameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			params = append(params, g.parameters(field.Type)...)
+			continue
+		}
+		for _, in := range [...]struct{ tag, in string }{{"param", "path"}, {"query", "query"}, {"header", "header"}} {
+			name, ok := field.Tag.Lookup(in.tag)
+			if !ok || name == "" || name == "-" {
+				continue
+			}
+			schema := g.schema(field.Type)
+			applyRules(schema, field)
+			if def, ok := field.Tag.Lookup("default"); ok {
+				schema.Description = "Defaults to " + def + "."
+			}
+			params = append(params, &OpenAPIParameter{
+				Name:     name,
+				In:       in.in,
+				Required: in.in == "path" || hasRule(field, "required"),
+				Schema:   schema,
+			})
+		}
+	}
+	return params
+}
+
+// bodySchema returns the schema of the fields of t bound from the request
+// body, or nil if there are none.
+func (g *schemaGenerator) bodySchema(t reflect.Type) *Schema {
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if base.Kind() != reflect.Struct || !hasBindingTags(base) {
+		return g.schema(t)
+	}
+
+	// The type is bound from both parameters and the body, so document the
+	// body fields inline.
+	s := g.structSchema(base, func(field reflect.StructField) bool {
+		return !isBindingField(field)
+	})
+	if len(s.Properties) == 0 {
+		return nil
+	}
+	return s
+}
+
+// schema returns the schema of t, registering named struct types as
+// components.
+func (g *schemaGenerator) schema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+
+	var s *Schema
+	switch {
+	case t == typeTimeTime:
+		s = &Schema{Type: "string", Format: "date-time"}
+	case t == typeTimeDuration:
+		s = &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds."}
+	case t == typeJSONRawMessage || t.Kind() == reflect.Interface:
+		s = &Schema{}
+	case reflect.PtrTo(t).Implements(typeJSONMarshaler) || t.Implements(typeJSONMarshaler):
+		s = &Schema{} // unknown, depends on MarshalJSON
+	case reflect.PtrTo(t).Implements(typeTextMarshaler) || t.Implements(typeTextMarshaler):
+		s = &Schema{Type: "string"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		s = &Schema{Type: "string", Format: "byte"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name, ok := g.names[t]
+		if !ok {
+			name = g.componentName(t)
+			g.schemas[name] = nil // placeholder for recursive types
+			g.schemas[name] = g.structSchema(t, nil)
+		}
+		s = &Schema{Ref: "#/components/schemas/" + name}
+	case t.Kind() == reflect.Struct:
+		s = g.structSchema(t, nil)
+	case t.Kind() == reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: g.schema(t.Elem())}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		s = &Schema{Type: "array", Items: g.schema(t.Elem())}
+	default:
+		s = primitiveSchema(t)
+	}
+
+	if nullable && s.Ref == "" {
+		s.Nullable = true
+	}
+	return s
+}
+
+// primitiveSchema returns the schema of a primitive type, using the JSON type
+// reported by jsonType.
+func primitiveSchema(t reflect.Type) *Schema {
+	switch jsonType(t) {
+	case "boolean":
+		return &Schema{Type: "boolean"}
+	case "integer":
+		switch t.Kind() {
+		case reflect.Int64, reflect.Uint64, reflect.Int, reflect.Uint:
+			return &Schema{Type: "integer", Format: "int64"}
+		default:
+			return &Schema{Type: "integer", Format: "int32"}
+		}
+	case "number":
+		if t.Kind() == reflect.Float32 {
+			return &Schema{Type: "number", Format: "float"}
+		}
+		return &Schema{Type: "number", Format: "double"}
+	case "string":
+		return &Schema{Type: "string"}
+	}
+
+	// Named primitive types, such as enums, are not reported by jsonType.
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	}
+	return &Schema{}
+}
+
+// structSchema returns the schema of the struct type t, including only the
+// fields for which include returns true, if it is not nil.
+func (g *schemaGenerator) structSchema(t reflect.Type, include func(reflect.StructField) bool) *Schema {
+	s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	var addFields func(t reflect.Type)
+	addFields = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if include != nil && !include(field) {
+				continue
+			}
+			tag := field.Tag.Get("json")
+			if field.Anonymous && field.Type.Kind() == reflect.Struct && tag == "" {
+				addFields(field.Type)
+				continue
+			}
+			if field.PkgPath != "" || tag == "-" {
+				continue
+			}
+
+			name := jsonFieldName(field)
+			fs := g.schema(field.Type)
+			applyRules(fs, field)
+			s.Properties[name] = fs
+			if hasRule(field, "required") {
+				s.Required = append(s.Required, name)
+			}
+		}
+	}
+	addFields(t)
+	sort.Strings(s.Required)
+	return s
+}
+
+// applyRules documents the rules of the field's "validate" tag in s.
+func applyRules(s *Schema, field reflect.StructField) {
+	rules := field.Tag.Get("validate")
+	if rules == "" || s.Ref != "" {
+		return
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "min", "max":
+			n, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				continue
+			}
+			switch s.Type {
+			case "string":
+				setInt(name, int(n), &s.MinLength, &s.MaxLength)
+			case "array":
+				setInt(name, int(n), &s.MinItems, &s.MaxItems)
+			case "integer", "number":
+				if name == "min" {
+					s.Minimum = &n
+				} else {
+					s.Maximum = &n
+				}
+			}
+		case "enum":
+			for _, v := range strings.Split(arg, "|") {
+				if n, err := strconv.ParseFloat(v, 64); err == nil && (s.Type == "integer" || s.Type == "number") {
+					s.Enum = append(s.Enum, n)
+				} else {
+					s.Enum = append(s.Enum, v)
+				}
+			}
+		case "regex":
+			s.Pattern = arg
+		}
+	}
+}
+
+// setInt stores n in min or max, according to the rule name.
+func setInt(rule string, n int, min, max **int) {
+	if rule == "min" {
+		*min = &n
+	} else {
+		*max = &n
+	}
+}
+
+// hasRule reports whether the field's "validate" tag contains the rule.
+func hasRule(field reflect.StructField, rule string) bool {
+	for _, r := range strings.Split(field.Tag.Get("validate"), ",") {
+		if r == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// hasBindingTags reports whether any field of the struct type t is bound from
+// the URL parameters, querystring or headers.
+func hasBindingTags(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if isBindingField(field) {
+			return true
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && hasBindingTags(field.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBindingField reports whether the field is bound from the URL parameters,
+// querystring or headers.
+func isBindingField(field reflect.StructField) bool {
+	for _, tag := range [...]string{"param", "query", "header"} {
+		if _, ok := field.Tag.Lookup(tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// errorMediaType returns the media type of errors rendered by the router.
+func errorMediaType(r *Router) string {
+	if r.problemDetails {
+		return ProblemDetailsContentType
+	}
+	return "application/json"
+}
+
+// errorSchema returns the schema of errors rendered by the router.
+func (g *schemaGenerator) errorSchema(r *Router) *Schema {
+	if r.problemDetails {
+		if _, ok := g.schemas["ProblemDetails"]; !ok {
+			g.schemas["ProblemDetails"] = &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"type":       {Type: "string"},
+					"title":      {Type: "string"},
+					"status":     {Type: "integer"},
+					"detail":     {Type: "string"},
+					"instance":   {Type: "string"},
+					"code":       {Type: "string"},
+					"details":    {Type: "array", Items: &Schema{Type: "string"}},
+					"errors":     {Type: "array", Items: g.fieldErrorSchema()},
+					"request_id": {Type: "string"},
+				},
+				Required: []string{"status", "title", "type"},
+			}
+		}
+		return &Schema{Ref: "#/components/schemas/ProblemDetails"}
+	}
+
+	if _, ok := g.schemas["Error"]; !ok {
+		g.schemas["Error"] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"error": {
+					Type: "object",
+					Properties: map[string]*Schema{
+						"code":       {Type: "string"},
+						"message":    {Type: "string"},
+						"details":    {Type: "array", Items: &Schema{Type: "string"}},
+						"fields":     {Type: "array", Items: g.fieldErrorSchema()},
+						"request_id": {Type: "string"},
+					},
+					Required: []string{"code", "message"},
+				},
+			},
+			Required: []string{"error"},
+		}
+	}
+	return &Schema{Ref: "#/components/schemas/Error"}
+}
+
+// fieldErrorSchema returns the schema of a FieldError.
+func (g *schemaGenerator) fieldErrorSchema() *Schema {
+	return g.schema(reflect.TypeOf(FieldError{}))
+}
+
+var (
+	typeJSONRawMessage = reflect.TypeOf(json.RawMessage(nil))
+	typeJSONMarshaler  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeTextMarshaler  = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
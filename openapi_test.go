@@ -0,0 +1,154 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type testUser struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name" validate:"required,max=100"`
+	Role      string    `json:"role,omitempty" validate:"enum=admin|member"`
+	CreatedAt time.Time `json:"created_at"`
+	Manager   *testUser `json:"manager,omitempty"`
+}
+
+type testListUsers struct {
+	Limit int    `query:"limit" default:"20" validate:"max=100"`
+	OrgID string `param:"org_id"`
+}
+
+func TestOpenAPI(t *testing.T) {
+	noop := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) { return nil, nil }
+
+	r := jsonrest.NewRouter(jsonrest.WithOpenAPIInfo(jsonrest.OpenAPIInfo{Title: "Users", Version: "2.0.0"}))
+	r.ServeOpenAPI("/openapi.json")
+	r.Get("/orgs/:org_id/users", noop,
+		jsonrest.Summary("List users"),
+		jsonrest.Tags("users"),
+		jsonrest.RequestType(testListUsers{}),
+		jsonrest.ResponseType(http.StatusOK, []testUser{}),
+	)
+	r.Post("/users", noop,
+		jsonrest.RequestType(testUser{}),
+		jsonrest.ResponseType(http.StatusCreated, testUser{}),
+		jsonrest.Errors(jsonrest.Error(409, "conflict", "user already exists")),
+	)
+	r.Get("/users/:id", noop)
+
+	w := do(r, http.MethodGet, "/openapi.json", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 200)
+
+	var doc map[string]interface{}
+	assert.Must(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.JSONEqual(t, doc["info"], m{"title": "Users", "version": "2.0.0"})
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.Equal(t, len(paths), 3)
+	assert.JSONEqual(t, paths["/orgs/{org_id}/users"], m{
+		"get": m{
+			"summary": "List users",
+			"tags":    []string{"users"},
+			"parameters": []m{
+				{"name": "limit", "in": "query", "schema": m{"type": "integer", "format": "int64", "maximum": 100, "description": "Defaults to 20."}},
+				{"name": "org_id", "in": "path", "required": true, "schema": m{"type": "string"}},
+			},
+			"responses": m{
+				"200": m{
+					"description": "OK",
+					"content": m{
+						"application/json": m{"schema": m{"type": "array", "items": m{"$ref": "#/components/schemas/testUser"}}},
+					},
+				},
+			},
+		},
+	})
+	assert.JSONEqual(t, paths["/users"], m{
+		"post": m{
+			"requestBody": m{
+				"required": true,
+				"content": m{
+					"application/json": m{"schema": m{"$ref": "#/components/schemas/testUser"}},
+				},
+			},
+			"responses": m{
+				"201": m{
+					"description": "Created",
+					"content": m{
+						"application/json": m{"schema": m{"$ref": "#/components/schemas/testUser"}},
+					},
+				},
+				"409": m{
+					"description": "user already exists",
+					"content": m{
+						"application/json": m{"schema": m{"$ref": "#/components/schemas/Error"}},
+					},
+				},
+			},
+		},
+	})
+	assert.JSONEqual(t, paths["/users/{id}"], m{
+		"get": m{
+			"parameters": []m{
+				{"name": "id", "in": "path", "required": true, "schema": m{"type": "string"}},
+			},
+			"responses": m{
+				"200": m{"description": "OK"},
+			},
+		},
+	})
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEqual(t, schemas["testUser"], m{
+		"type": "object",
+		"properties": m{
+			"id":         m{"type": "integer", "format": "int64"},
+			"name":       m{"type": "string", "maxLength": 100},
+			"role":       m{"type": "string", "enum": []string{"admin", "member"}},
+			"created_at": m{"type": "string", "format": "date-time"},
+			"manager":    m{"$ref": "#/components/schemas/testUser"},
+		},
+		"required": []string{"name"},
+	})
+}
+
+func TestOpenAPIComponentNames(t *testing.T) {
+	noop := func(ctx context.Context, r *jsonrest.Request) (interface{}, error) { return nil, nil }
+	v1 := func() interface{} {
+		type User struct {
+			ID int `json:"id"`
+		}
+		return User{}
+	}()
+	v2 := func() interface{} {
+		type User struct {
+			Name string `json:"name"`
+		}
+		return User{}
+	}()
+	type Error struct {
+		Reason string `json:"reason"`
+	}
+
+	r := jsonrest.NewRouter()
+	r.Get("/v1/user", noop, jsonrest.ResponseType(http.StatusOK, v1))
+	r.Get("/v2/user", noop, jsonrest.ResponseType(http.StatusOK, v2))
+	r.Get("/error", noop, jsonrest.ResponseType(http.StatusOK, Error{}), jsonrest.Errors(jsonrest.NotFound("not found")))
+
+	b, err := json.Marshal(r.OpenAPI())
+	assert.Must(t, err)
+	var doc map[string]interface{}
+	assert.Must(t, json.Unmarshal(b, &doc))
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEqual(t, schemas["User"], m{"type": "object", "properties": m{"id": m{"type": "integer", "format": "int64"}}})
+	assert.JSONEqual(t, schemas["jsonrest-go_test.User"], m{"type": "object", "properties": m{"name": m{"type": "string"}}})
+	assert.JSONEqual(t, schemas["jsonrest-go_test.Error"], m{"type": "object", "properties": m{"reason": m{"type": "string"}}})
+	assert.Equal(t, schemas["Error"].(map[string]interface{})["required"], []interface{}{"error"})
+}
@@ -0,0 +1,601 @@
+package jsonrest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// LoadOpenAPI reads an OpenAPI 3 document from a JSON or YAML file. Files with
+// a .yaml or .yml extension are read as YAML.
+func LoadOpenAPI(path string) (*OpenAPIDocument, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var v interface{}
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("jsonrest: cannot parse %s: %v", path, err)
+		}
+		if data, err = json.Marshal(yamlToJSON(v)); err != nil {
+			return nil, fmt.Errorf("jsonrest: cannot parse %s: %v", path, err)
+		}
+	}
+
+	var doc OpenAPIDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("jsonrest: cannot parse %s: %v", path, err)
+	}
+	return &doc, nil
+}
+
+// yamlToJSON converts the maps decoded by yaml.v2, which have interface{}
+// keys, into maps which can be encoded as JSON.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			m[fmt.Sprint(k)] = yamlToJSON(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = yamlToJSON(val)
+		}
+	}
+	return v
+}
+
+// An OpenAPIValidationOption configures the middleware returned by
+// ValidateOpenAPI.
+type OpenAPIValidationOption func(*openAPIValidator)
+
+// ValidateResponses is an OpenAPIValidationOption which also validates the
+// bodies of successful responses against the document. Responses that do not
+// match are replaced with an HTTP 500 error listing the violations. As this is
+// expensive, it is intended for use in tests.
+func ValidateResponses() OpenAPIValidationOption {
+	return func(v *openAPIValidator) {
+		v.responses = true
+	}
+}
+
+// ValidateOpenAPI returns a Middleware which validates requests against the
+// operation of doc matching the route and method of the request, before the
+// endpoint is called. Requests to routes that are not in the document are not
+// validated.
+//
+// Path, query and header parameters which do not match the document are
+// rejected with an HTTP 400 Bad Request error listing each violation in its
+// details. JSON request bodies which do not match are rejected with an HTTP
+// 422 Unprocessable Entity error listing each violation by its JSON pointer.
+//
+// Only schemas referenced with "#/components/schemas/" are resolved. An error
+// is returned if a pattern of the document is not a valid regular expression
+// of the regexp package, which does not support all ECMA-262 syntax.
+func ValidateOpenAPI(doc *OpenAPIDocument, opts ...OpenAPIValidationOption) (Middleware, error) {
+	v := &openAPIValidator{
+		doc:        doc,
+		operations: make(map[string]*openAPIRoute),
+		patterns:   make(map[string]*regexp.Regexp),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			key := strings.ToUpper(method) + " " + pathShape(path)
+			v.operations[key] = &openAPIRoute{path: path, op: op}
+		}
+	}
+	if err := v.compilePatterns(); err != nil {
+		return nil, err
+	}
+
+	return v.middleware, nil
+}
+
+// middleware validates the requests to next.
+func (v *openAPIValidator) middleware(next Endpoint) Endpoint {
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		rt, ok := v.match(req)
+		if !ok {
+			return next(ctx, req)
+		}
+		if err := v.validateRequest(req, rt); err != nil {
+			return nil, err
+		}
+
+		result, err := next(ctx, req)
+		if err != nil || !v.responses {
+			return result, err
+		}
+		if err := v.validateResponse(rt, result); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// compilePatterns compiles the patterns of the schemas of the document.
+func (v *openAPIValidator) compilePatterns() error {
+	seen := make(map[*Schema]bool)
+	var compile func(s *Schema) error
+	compile = func(s *Schema) error {
+		if s == nil || seen[s] {
+			return nil
+		}
+		seen[s] = true
+		if s.Pattern != "" {
+			re, err := regexp.Compile(s.Pattern)
+			if err != nil {
+				return fmt.Errorf("jsonrest: invalid pattern %q: %v", s.Pattern, err)
+			}
+			v.patterns[s.Pattern] = re
+		}
+		children := []*Schema{s.AdditionalProperties, s.Items}
+		children = append(children, s.AllOf...)
+		children = append(children, s.OneOf...)
+		children = append(children, s.AnyOf...)
+		for _, p := range s.Properties {
+			children = append(children, p)
+		}
+		for _, c := range children {
+			if err := compile(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	compileContent := func(content map[string]*OpenAPIMediaType) error {
+		for _, media := range content {
+			if err := compile(media.Schema); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if v.doc.Components != nil {
+		for _, s := range v.doc.Components.Schemas {
+			if err := compile(s); err != nil {
+				return err
+			}
+		}
+	}
+	for _, rt := range v.operations {
+		for _, p := range rt.op.Parameters {
+			if err := compile(p.Schema); err != nil {
+				return err
+			}
+		}
+		if body := rt.op.RequestBody; body != nil {
+			if err := compileContent(body.Content); err != nil {
+				return err
+			}
+		}
+		for _, res := range rt.op.Responses {
+			if err := compileContent(res.Content); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openAPIValidator validates requests against an OpenAPI document.
+type openAPIValidator struct {
+	doc        *OpenAPIDocument
+	operations map[string]*openAPIRoute
+	responses  bool
+
+	// patterns are the compiled patterns of the schemas of the document.
+	patterns map[string]*regexp.Regexp
+}
+
+// openAPIRoute is an operation of an OpenAPI document and its path template.
+type openAPIRoute struct {
+	path string
+	op   *OpenAPIOperation
+}
+
+// pathShape returns the path template with parameter names removed, so that
+// paths can be matched regardless of how their parameters are named.
+func pathShape(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = "{}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// match returns the operation matching the route and method of req.
+func (v *openAPIValidator) match(req *Request) (*openAPIRoute, bool) {
+	path, _ := openAPIPath(req.Route())
+	rt, ok := v.operations[req.Method()+" "+pathShape(path)]
+	return rt, ok
+}
+
+// validateRequest validates the parameters and body of req.
+func (v *openAPIValidator) validateRequest(req *Request, rt *openAPIRoute) error {
+	var details []string
+	for _, p := range rt.op.Parameters {
+		values := v.parameterValues(req, rt.path, p)
+		if len(values) == 0 {
+			if p.Required {
+				details = append(details, fmt.Sprintf("%s parameter %q is required", p.In, p.Name))
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+
+		val := parseParameter(values, v.resolve(p.Schema))
+		var fields []FieldError
+		v.validateSchema(p.Schema, val, "", &fields)
+		for _, f := range fields {
+			details = append(details, fmt.Sprintf("%s parameter %q %s", p.In, p.Name, f.Message))
+		}
+	}
+	if len(details) > 0 {
+		err := BadRequest("request does not match the API specification")
+		err.Details = details
+		return err
+	}
+
+	body := rt.op.RequestBody
+	if body == nil {
+		return nil
+	}
+	media, ok := jsonMediaType(body.Content)
+	if !ok || media.Schema == nil {
+		return nil
+	}
+
+	// Requests created with NewTestRequest have no router, and so no size
+	// limit.
+	var r io.Reader = req.req.Body
+	if req.router != nil {
+		if max := req.router.decodeOptions.maxSize; max > 0 {
+			r = &maxSizeReader{r: r, remaining: max, max: max}
+		}
+	}
+	data, err := ioutil.ReadAll(r)
+	if tooLarge := bodyTooLarge(err); tooLarge != nil {
+		return tooLarge
+	} else if err != nil {
+		return BadRequest("cannot read request body").Wrap(err)
+	}
+	req.req.Body.Close()
+	req.req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if len(bytes.TrimSpace(data)) == 0 {
+		if body.Required {
+			return UnprocessableEntity("request body is required")
+		}
+		return nil
+	}
+	if !isJSONMediaType(mediaType(req.Header("Content-Type"))) && req.Header("Content-Type") != "" {
+		return nil
+	}
+
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		msg := "malformed or unexpected json"
+		if details := jsonErrorDetails(err); details != "" {
+			msg += ": " + details
+		}
+		return BadRequest(msg).Wrap(err)
+	}
+	var fields []FieldError
+	v.validateSchema(media.Schema, val, "", &fields)
+	if len(fields) > 0 {
+		err := UnprocessableEntity("request body does not match the API specification")
+		err.Fields = fields
+		return err
+	}
+	return nil
+}
+
+// validateResponse validates the body of a successful response.
+func (v *openAPIValidator) validateResponse(rt *openAPIRoute, result interface{}) error {
+	status, body := http.StatusOK, result
+	if res, ok := result.(Response); ok {
+		body = res.Body
+		if res.StatusCode != 0 {
+			status = res.StatusCode
+		}
+	}
+
+	res := rt.op.Responses[strconv.Itoa(status)]
+	if res == nil {
+		res = rt.op.Responses[strconv.Itoa(status/100)+"XX"]
+	}
+	if res == nil {
+		res = rt.op.Responses["default"]
+	}
+	if res == nil {
+		return invalidResponse([]string{fmt.Sprintf("status %d is not documented", status)}, nil)
+	}
+	media, ok := jsonMediaType(res.Content)
+	if !ok || media.Schema == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return err
+	}
+	var fields []FieldError
+	v.validateSchema(media.Schema, val, "", &fields)
+	if len(fields) > 0 {
+		return invalidResponse(nil, fields)
+	}
+	return nil
+}
+
+// invalidResponse returns the error for a response which does not match the
+// document.
+func invalidResponse(details []string, fields []FieldError) error {
+	err := Error(500, "invalid_response", "response does not match the API specification")
+	err.Details = details
+	err.Fields = fields
+	return err
+}
+
+// jsonMediaType returns the JSON media type of content, if any.
+func jsonMediaType(content map[string]*OpenAPIMediaType) (*OpenAPIMediaType, bool) {
+	for mt, media := range content {
+		if isJSONMediaType(mediaType(mt)) && media != nil {
+			return media, true
+		}
+	}
+	return nil, false
+}
+
+// parameterValues returns the raw values of the parameter p.
+func (v *openAPIValidator) parameterValues(req *Request, path string, p *OpenAPIParameter) []string {
+	switch p.In {
+	case "path":
+		// Parameters are matched by position, since the route and document
+		// may name them differently.
+		docParts := strings.Split(path, "/")
+		routeParts := strings.Split(req.Route(), "/")
+		for i, part := range docParts {
+			if part == "{"+p.Name+"}" && i < len(routeParts) && len(routeParts[i]) > 1 {
+				if val := req.Param(routeParts[i][1:]); val != "" {
+					return []string{val}
+				}
+			}
+		}
+		return nil
+	case "query":
+		return req.req.URL.Query()[p.Name]
+	case "header":
+		return req.req.Header[http.CanonicalHeaderKey(p.Name)]
+	}
+	return nil
+}
+
+// parseParameter converts the raw values of a parameter to the type of its
+// schema. Values which cannot be converted are returned as strings, to be
+// reported by validation.
+func parseParameter(values []string, s *Schema) interface{} {
+	if s.Type == "array" {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := &Schema{}
+		if s.Items != nil {
+			items = s.Items
+		}
+		result := make([]interface{}, len(values))
+		for i, val := range values {
+			result[i] = parseParameter([]string{val}, items)
+		}
+		return result
+	}
+
+	val := values[0]
+	switch s.Type {
+	case "integer", "number":
+		if n, err := strconv.ParseFloat(val, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	}
+	return val
+}
+
+// resolve returns the schema referenced by s, if it is a reference.
+func (v *openAPIValidator) resolve(s *Schema) *Schema {
+	for i := 0; s.Ref != "" && i < 32; i++ {
+		name := strings.TrimPrefix(s.Ref, "#/components/schemas/")
+		if v.doc.Components == nil || v.doc.Components.Schemas[name] == nil {
+			return &Schema{}
+		}
+		s = v.doc.Components.Schemas[name]
+	}
+	return s
+}
+
+// validateSchema validates the JSON value val, located at the JSON pointer
+// path, against the schema s, appending any violations to fields.
+func (v *openAPIValidator) validateSchema(s *Schema, val interface{}, path string, fields *[]FieldError) {
+	s = v.resolve(s)
+	violation := func(format string, args ...interface{}) {
+		*fields = append(*fields, FieldError{Pointer: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if val == nil {
+		if !s.Nullable && s.Type != "" {
+			violation("must not be null")
+		}
+		return
+	}
+
+	for _, sub := range s.AllOf {
+		v.validateSchema(sub, val, path, fields)
+	}
+	if len(s.OneOf) > 0 && v.countMatches(s.OneOf, val) != 1 {
+		violation("must match exactly one schema in oneOf")
+	}
+	if len(s.AnyOf) > 0 && v.countMatches(s.AnyOf, val) == 0 {
+		violation("must match at least one schema in anyOf")
+	}
+
+	if s.Type != "" && !matchesType(s.Type, val) {
+		violation("must be %s", s.Type)
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, val) {
+		values := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			values[i] = fmt.Sprint(e)
+		}
+		violation("must be one of %s", strings.Join(values, ", "))
+	}
+
+	switch val := val.(type) {
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			violation("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			violation("must be at most %v", *s.Maximum)
+		}
+	case string:
+		n := utf8.RuneCountInString(val)
+		if s.MinLength != nil && n < *s.MinLength {
+			violation("length must be at least %d", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			violation("length must be at most %d", *s.MaxLength)
+		}
+		if s.Pattern != "" && !v.patterns[s.Pattern].MatchString(val) {
+			violation("must match %s", s.Pattern)
+		}
+		if msg := checkFormat(s.Format, val); msg != "" {
+			violation("%s", msg)
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(val) < *s.MinItems {
+			violation("length must be at least %d", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(val) > *s.MaxItems {
+			violation("length must be at most %d", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range val {
+				v.validateSchema(s.Items, item, path+"/"+strconv.Itoa(i), fields)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*fields = append(*fields, FieldError{Pointer: path + "/" + escapePointer(name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop := val[name]
+			propPath := path + "/" + escapePointer(name)
+			switch {
+			case s.Properties[name] != nil:
+				v.validateSchema(s.Properties[name], prop, propPath, fields)
+			case s.AdditionalProperties != nil:
+				v.validateSchema(s.AdditionalProperties, prop, propPath, fields)
+			case s.NoAdditionalProperties:
+				*fields = append(*fields, FieldError{Pointer: propPath, Message: "is not allowed"})
+			}
+		}
+	}
+}
+
+// countMatches returns the number of schemas which val matches.
+func (v *openAPIValidator) countMatches(schemas []*Schema, val interface{}) int {
+	n := 0
+	for _, s := range schemas {
+		var fields []FieldError
+		v.validateSchema(s, val, "", &fields)
+		if len(fields) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesType reports whether the JSON value val is of the JSON Schema type t.
+func matchesType(t string, val interface{}) bool {
+	switch val := val.(type) {
+	case bool:
+		return t == "boolean"
+	case float64:
+		return t == "number" || (t == "integer" && val == math.Trunc(val))
+	case string:
+		return t == "string"
+	case []interface{}:
+		return t == "array"
+	case map[string]interface{}:
+		return t == "object"
+	}
+	return false
+}
+
+// inEnum reports whether val is one of the enum values.
+func inEnum(enum []interface{}, val interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, val) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFormat checks the string val against the common formats date-time and
+// date, returning a message if it does not match. Other formats are not
+// checked.
+func checkFormat(format, val string) string {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, val); err != nil {
+			return "must be a date-time"
+		}
+	case "date":
+		if _, err := time.Parse("2006-01-02", val); err != nil {
+			return "must be a date"
+		}
+	}
+	return ""
+}
@@ -0,0 +1,291 @@
+package jsonrest_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: Users
+  version: 1.0.0
+paths:
+  /users/{user_id}:
+    parameters:
+      - name: user_id
+        in: path
+        required: true
+        schema:
+          type: integer
+          minimum: 1
+    get:
+      parameters:
+        - name: fields
+          in: query
+          schema:
+            type: array
+            items:
+              type: string
+              enum: [id, name]
+        - name: X-Tenant
+          in: header
+          required: true
+          schema:
+            type: string
+      responses:
+        "200":
+          description: OK
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+  /users:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: "#/components/schemas/User"
+      responses:
+        "201":
+          description: Created
+components:
+  schemas:
+    User:
+      type: object
+      required: [id, name]
+      additionalProperties: false
+      properties:
+        id:
+          type: integer
+        name:
+          type: string
+          maxLength: 5
+        email:
+          type: string
+          nullable: true
+        tags:
+          type: array
+          items:
+            type: string
+            pattern: "^[a-z]+$"
+`
+
+func loadTestSpec(t *testing.T) *jsonrest.OpenAPIDocument {
+	dir, err := ioutil.TempDir("", "jsonrest")
+	assert.Must(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "openapi.yaml")
+	assert.Must(t, ioutil.WriteFile(path, []byte(testSpec), 0600))
+	doc, err := jsonrest.LoadOpenAPI(path)
+	assert.Must(t, err)
+	return doc
+}
+
+func TestValidateOpenAPI(t *testing.T) {
+	doc := loadTestSpec(t)
+
+	var user interface{} = m{"id": 1, "name": "alice"}
+	r := jsonrest.NewRouter()
+	validate, err := jsonrest.ValidateOpenAPI(doc, jsonrest.ValidateResponses())
+	assert.Must(t, err)
+	r.Use(validate)
+	r.Get("/users/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return user, nil
+	})
+	r.Post("/users", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		var body m
+		if err := req.BindBody(&body); err != nil {
+			return nil, err
+		}
+		return jsonrest.Response{StatusCode: http.StatusCreated, Body: body}, nil
+	})
+	r.Get("/other", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return m{"ok": true}, nil
+	})
+
+	tenant := map[string]string{"X-Tenant": "acme"}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		body    string
+		headers map[string]string
+		user    interface{}
+		status  int
+		want    m
+	}{
+		{
+			name:    "valid get",
+			method:  http.MethodGet,
+			path:    "/users/1?fields=id,name",
+			headers: tenant,
+			status:  200,
+		},
+		{
+			name:   "invalid parameters",
+			method: http.MethodGet,
+			path:   "/users/0?fields=email",
+			status: 400,
+			want: m{"error": m{
+				"code":    "bad_request",
+				"message": "request does not match the API specification",
+				"details": []string{
+					`query parameter "fields" must be one of id, name`,
+					`header parameter "X-Tenant" is required`,
+					`path parameter "user_id" must be at least 1`,
+				},
+			}},
+		},
+		{
+			name:    "path parameter of wrong type",
+			method:  http.MethodGet,
+			path:    "/users/abc",
+			headers: tenant,
+			status:  400,
+			want: m{"error": m{
+				"code":    "bad_request",
+				"message": "request does not match the API specification",
+				"details": []string{`path parameter "user_id" must be integer`},
+			}},
+		},
+		{
+			name:   "valid body",
+			method: http.MethodPost,
+			path:   "/users",
+			body:   `{"id": 1, "name": "bob", "email": null, "tags": ["a"]}`,
+			status: 201,
+		},
+		{
+			name:   "invalid body",
+			method: http.MethodPost,
+			path:   "/users",
+			body:   `{"id": 1.5, "name": "robert", "tags": [1], "admin": true}`,
+			status: 422,
+			want: m{"error": m{
+				"code":    "unprocessable_entity",
+				"message": "request body does not match the API specification",
+				"fields": []m{
+					{"pointer": "/admin", "message": "is not allowed"},
+					{"pointer": "/id", "message": "must be integer"},
+					{"pointer": "/name", "message": "length must be at most 5"},
+					{"pointer": "/tags/0", "message": "must be string"},
+				},
+			}},
+		},
+		{
+			name:   "missing body",
+			method: http.MethodPost,
+			path:   "/users",
+			status: 422,
+			want: m{"error": m{
+				"code":    "unprocessable_entity",
+				"message": "request body is required",
+			}},
+		},
+		{
+			name:    "invalid response",
+			method:  http.MethodGet,
+			path:    "/users/1",
+			headers: tenant,
+			user:    m{"id": "1"},
+			status:  500,
+			want: m{"error": m{
+				"code":    "invalid_response",
+				"message": "response does not match the API specification",
+				"fields": []m{
+					{"pointer": "/name", "message": "is required"},
+					{"pointer": "/id", "message": "must be integer"},
+				},
+			}},
+		},
+		{
+			name:   "undocumented route",
+			method: http.MethodGet,
+			path:   "/other",
+			status: 200,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user = m{"id": 1, "name": "alice"}
+			if tt.user != nil {
+				user = tt.user
+			}
+			w := do(r, tt.method, tt.path, strings.NewReader(tt.body), "application/json", tt.headers)
+			assert.Equal(t, w.Result().StatusCode, tt.status)
+			if tt.want != nil {
+				assert.JSONEqual(t, w.Body.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateOpenAPIPatterns(t *testing.T) {
+	doc := loadTestSpec(t)
+	validate, err := jsonrest.ValidateOpenAPI(doc)
+	assert.Must(t, err)
+
+	r := jsonrest.NewRouter(jsonrest.WithMaxBodySize(48))
+	r.Use(validate)
+	r.Post("/users", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+
+	w := do(r, http.MethodPost, "/users", strings.NewReader(`{"id": 1, "name": "bob", "tags": ["A"]}`), "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 422)
+	assert.JSONEqual(t, w.Body.String(), m{"error": m{
+		"code":    "unprocessable_entity",
+		"message": "request body does not match the API specification",
+		"fields":  []m{{"pointer": "/tags/0", "message": "must match ^[a-z]+$"}},
+	}})
+
+	w = do(r, http.MethodPost, "/users", strings.NewReader(`{"id": 1, "name": "bob", "tags": ["abcdefghijklmnopqrstuvwxyz"]}`), "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 413)
+
+	doc.Components.Schemas["User"].Properties["name"].Pattern = "^(?=a)"
+	_, err = jsonrest.ValidateOpenAPI(doc)
+	assert.True(t, err != nil)
+}
+
+func TestValidateOpenAPITestRequest(t *testing.T) {
+	validate, err := jsonrest.ValidateOpenAPI(loadTestSpec(t))
+	assert.Must(t, err)
+	endpoint := validate(func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, nil
+	})
+
+	body := strings.NewReader(`{"id": 1, "name": "bob"}`)
+	httpReq := httptest.NewRequest(http.MethodPost, "/users", body)
+	httpReq.Header.Set("Content-Type", "application/json")
+	req := jsonrest.NewTestRequest(nil, httpReq, "/users")
+	_, err = endpoint(context.Background(), &req)
+	assert.Must(t, err)
+}
+
+func TestLoadOpenAPI(t *testing.T) {
+	doc := loadTestSpec(t)
+	assert.Equal(t, doc.Info.Title, "Users")
+
+	op := doc.Paths["/users/{user_id}"]["get"]
+	assert.Equal(t, len(op.Parameters), 3)
+	assert.Equal(t, op.Parameters[2].Name, "user_id")
+
+	user := doc.Components.Schemas["User"]
+	assert.True(t, user.NoAdditionalProperties)
+	assert.Equal(t, user.Properties["name"].Type, "string")
+}
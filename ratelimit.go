@@ -0,0 +1,206 @@
+package jsonrest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimit is the number of requests allowed per period by the RateLimiter
+// middleware.
+type RateLimit struct {
+	// Requests is the number of requests allowed per Period.
+	Requests int
+
+	// Period is the period over which Requests are allowed. For
+	// SlidingWindow limits, it is the length of the window.
+	Period time.Duration
+
+	// Algorithm is the algorithm enforcing the limit. It defaults to
+	// TokenBucket.
+	Algorithm RateLimitAlgorithm
+}
+
+// A RateLimitAlgorithm is an algorithm enforcing a RateLimit.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Requests requests, and replenishes
+	// the allowance continuously, at a rate of Requests per Period.
+	TokenBucket RateLimitAlgorithm = iota
+
+	// SlidingWindow allows Requests requests in any window of length Period,
+	// approximated by weighting the count of the previous fixed window by its
+	// overlap with the sliding window.
+	SlidingWindow
+)
+
+// RateLimitResult is the outcome of counting a request against a RateLimit.
+type RateLimitResult struct {
+	// Allowed reports whether the request is within the limit.
+	Allowed bool
+
+	// Remaining is the number of requests still allowed.
+	Remaining int
+
+	// Reset is the time until the allowance is replenished. For TokenBucket
+	// limits, it is the time until the bucket is full again, i.e. until all
+	// Requests are allowed. For SlidingWindow limits, it is the time until the
+	// current window ends.
+	Reset time.Duration
+
+	// RetryAfter is the time until a request is allowed again. It is only set
+	// if the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// A RateLimitStore counts requests against rate limits. The state of each
+// limit is kept separately for each key, and a store may be shared by several
+// RateLimiter middleware with different limits. Implementations must be safe
+// for concurrent use.
+type RateLimitStore interface {
+	// Allow counts a request with the given key against limit.
+	Allow(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// A RateLimitOption configures the RateLimiter middleware.
+type RateLimitOption func(*rateLimitConfig)
+
+// rateLimitConfig is the configuration of the RateLimiter middleware.
+type rateLimitConfig struct {
+	key   func(*Request) string
+	store RateLimitStore
+}
+
+// RateLimitByIP is a RateLimitOption which limits requests by client IP
+// address, as given by the remote address of the connection. It is the
+// default. Routers behind a proxy should use RateLimitBy with the client
+// address forwarded by the proxy instead.
+func RateLimitByIP() RateLimitOption {
+	return RateLimitBy(clientIP)
+}
+
+// RateLimitByHeader is a RateLimitOption which limits requests by the value
+// of the given header, such as an API key.
+func RateLimitByHeader(name string) RateLimitOption {
+	return RateLimitBy(func(req *Request) string {
+		return req.Header(name)
+	})
+}
+
+// RateLimitByBasicAuth is a RateLimitOption which limits requests by the
+// username of their HTTP Basic Authentication credentials.
+func RateLimitByBasicAuth() RateLimitOption {
+	return RateLimitBy(func(req *Request) string {
+		username, _, _ := req.BasicAuth()
+		return username
+	})
+}
+
+// RateLimitBy is a RateLimitOption which limits requests by the key returned
+// by fn. Requests for which fn returns an empty key are limited by client IP
+// address.
+func RateLimitBy(fn func(*Request) string) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.key = fn
+	}
+}
+
+// RateLimitStorage is a RateLimitOption which sets the store counting
+// requests, such as one shared by several instances of a service. It defaults
+// to a new MemoryRateLimitStore.
+func RateLimitStorage(store RateLimitStore) RateLimitOption {
+	return func(c *rateLimitConfig) {
+		c.store = store
+	}
+}
+
+// RateLimiter returns a Middleware which limits the rate of requests from each
+// client to limit. Requests exceeding the limit are rejected with an HTTP 429
+// Too Many Requests error with the code "rate_limited" and a Retry-After
+// header, e.g.
+//
+//	r.Use(jsonrest.RateLimiter(
+//	    jsonrest.RateLimit{Requests: 100, Period: time.Minute},
+//	    jsonrest.RateLimitByHeader("X-API-Key"),
+//	))
+//
+// All responses carry the RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers describing the limit, where
+// RateLimit-Reset is the RateLimitResult.Reset of the request in seconds.
+// Errors from the store are returned as internal errors, whose message does
+// not include that of the store error, so that it is not exposed by
+// Router.DumpErrors. The store error can be retrieved with errors.Unwrap, e.g.
+// by an ErrorHandler. It panics if the limit is not positive.
+func RateLimiter(limit RateLimit, opts ...RateLimitOption) Middleware {
+	if limit.Requests <= 0 || limit.Period <= 0 {
+		panic(fmt.Sprintf("jsonrest: invalid rate limit %d per %v", limit.Requests, limit.Period))
+	}
+	c := rateLimitConfig{key: clientIP}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.store == nil {
+		c.store = NewMemoryRateLimitStore()
+	}
+	policy := strconv.Itoa(limit.Requests) + ";w=" + formatSeconds(limit.Period)
+
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			key := c.key(req)
+			if key == "" {
+				key = clientIP(req)
+			}
+			res, err := c.store.Allow(ctx, key, limit)
+			if err != nil {
+				return nil, &rateLimitStoreError{err: err}
+			}
+
+			req.SetResponseHeader("RateLimit-Limit", strconv.Itoa(limit.Requests))
+			req.SetResponseHeader("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			req.SetResponseHeader("RateLimit-Reset", formatSeconds(res.Reset))
+			req.SetResponseHeader("RateLimit-Policy", policy)
+			if !res.Allowed {
+				retryAfter := formatSeconds(res.RetryAfter)
+				if retryAfter == "0" {
+					retryAfter = "1"
+				}
+				req.SetResponseHeader("Retry-After", retryAfter)
+				return nil, Error(http.StatusTooManyRequests, "rate_limited", "rate limit exceeded")
+			}
+			return next(ctx, req)
+		}
+	}
+}
+
+// rateLimitStoreError is an error from a RateLimitStore. Its message omits
+// that of the store error, which may describe the infrastructure of the store.
+type rateLimitStoreError struct {
+	err error
+}
+
+func (e *rateLimitStoreError) Error() string {
+	return "jsonrest: rate limit store failed"
+}
+
+func (e *rateLimitStoreError) Unwrap() error {
+	return e.err
+}
+
+// clientIP returns the IP address of the client of req.
+func clientIP(req *Request) string {
+	addr := req.Raw().RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// formatSeconds formats d as a whole number of seconds, rounded up.
+func formatSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
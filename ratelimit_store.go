@@ -0,0 +1,166 @@
+package jsonrest
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// rateLimitSweepInterval is the minimum interval between sweeps of expired
+// entries from a MemoryRateLimitStore.
+const rateLimitSweepInterval = time.Minute
+
+// MemoryRateLimitStore is a RateLimitStore which keeps the state of rate
+// limits in memory, so it only limits the requests to a single process.
+// Entries are evicted once their limit is fully replenished, so memory use is
+// bounded by the number of clients seen in the longest limit period.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	entries   map[rateLimitKey]*rateLimitEntry
+	nextSweep time.Time
+
+	// now returns the current time, and is replaced in tests.
+	now func() time.Time
+}
+
+// rateLimitKey identifies the state of a limit for a key.
+type rateLimitKey struct {
+	key   string
+	limit RateLimit
+}
+
+// rateLimitEntry is the state of a limit for a key.
+type rateLimitEntry struct {
+	// tokens is the number of tokens in the bucket as of updated, for
+	// TokenBucket limits.
+	tokens  float64
+	updated time.Time
+
+	// start is the start of the current window, and prev and curr the
+	// number of requests in the previous and current windows, for
+	// SlidingWindow limits.
+	start time.Time
+	prev  int
+	curr  int
+
+	// expires is the time at which the entry no longer affects the limit.
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore returns a new MemoryRateLimitStore.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		entries: make(map[rateLimitKey]*rateLimitEntry),
+		now:     time.Now,
+	}
+}
+
+// Allow implements the RateLimitStore interface.
+func (s *MemoryRateLimitStore) Allow(_ context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if !now.Before(s.nextSweep) {
+		s.sweep(now)
+	}
+
+	k := rateLimitKey{key: key, limit: limit}
+	e, ok := s.entries[k]
+	if !ok {
+		e = &rateLimitEntry{tokens: float64(limit.Requests), updated: now, start: now.Truncate(limit.Period)}
+		s.entries[k] = e
+	}
+	if limit.Algorithm == SlidingWindow {
+		return e.allowSlidingWindow(now, limit), nil
+	}
+	return e.allowTokenBucket(now, limit), nil
+}
+
+// sweep evicts the entries which have expired by now.
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for k, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, k)
+		}
+	}
+	s.nextSweep = now.Add(rateLimitSweepInterval)
+}
+
+// allowTokenBucket counts a request at now against a TokenBucket limit.
+func (e *rateLimitEntry) allowTokenBucket(now time.Time, limit RateLimit) RateLimitResult {
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+	e.tokens = math.Min(capacity, e.tokens+now.Sub(e.updated).Seconds()*rate)
+	e.updated = now
+
+	var res RateLimitResult
+	if e.tokens >= 1 {
+		e.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = seconds((1 - e.tokens) / rate)
+	}
+	res.Remaining = int(e.tokens)
+	res.Reset = seconds((capacity - e.tokens) / rate)
+	e.expires = now.Add(res.Reset)
+	return res
+}
+
+// allowSlidingWindow counts a request at now against a SlidingWindow limit.
+func (e *rateLimitEntry) allowSlidingWindow(now time.Time, limit RateLimit) RateLimitResult {
+	period := limit.Period
+	if end := e.start.Add(period); !now.Before(end) {
+		e.prev = 0
+		if now.Before(end.Add(period)) {
+			e.prev = e.curr
+		}
+		e.curr = 0
+		e.start = now.Truncate(period)
+	}
+
+	// The weight of the previous window is the fraction of the sliding
+	// window which overlaps it.
+	elapsed := now.Sub(e.start)
+	weight := float64(period-elapsed) / float64(period)
+	count := float64(e.prev)*weight + float64(e.curr)
+
+	var res RateLimitResult
+	if count+1 <= float64(limit.Requests) {
+		e.curr++
+		count++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = e.retryAfter(elapsed, limit)
+	}
+	res.Remaining = int(math.Max(0, float64(limit.Requests)-count))
+	res.Reset = period - elapsed
+	e.expires = e.start.Add(2 * period)
+	return res
+}
+
+// retryAfter returns the time until a request is allowed by a SlidingWindow
+// limit, elapsed into the current window.
+func (e *rateLimitEntry) retryAfter(elapsed time.Duration, limit RateLimit) time.Duration {
+	period := float64(limit.Period)
+	allowed := float64(limit.Requests - 1)
+	if curr := float64(e.curr); curr <= allowed {
+		// Wait for the weight of the previous window to decay, within the
+		// current window.
+		at := period - (allowed-curr)/float64(e.prev)*period
+		if wait := time.Duration(at) - elapsed; wait > 0 {
+			return wait
+		}
+		return 0
+	}
+	// Wait for the current window to become the previous one, and its
+	// weight to decay.
+	at := period - allowed/float64(e.curr)*period
+	return limit.Period - elapsed + time.Duration(at)
+}
+
+// seconds converts a number of seconds to a duration.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,65 @@
+package jsonrest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryRateLimitStore()
+	s.now = func() time.Time { return now }
+
+	allow := func(key string, limit RateLimit) RateLimitResult {
+		res, err := s.Allow(ctx, key, limit)
+		assert.Must(t, err)
+		return res
+	}
+
+	t.Run("token bucket", func(t *testing.T) {
+		limit := RateLimit{Requests: 2, Period: 10 * time.Second}
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 1, Reset: 5 * time.Second})
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 0, Reset: 10 * time.Second})
+		assert.Equal(t, allow("a", limit), RateLimitResult{Remaining: 0, Reset: 10 * time.Second, RetryAfter: 5 * time.Second})
+
+		// Other keys and limits are counted separately.
+		assert.True(t, allow("b", limit).Allowed)
+		assert.True(t, allow("a", RateLimit{Requests: 3, Period: 10 * time.Second}).Allowed)
+
+		now = now.Add(5 * time.Second)
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 0, Reset: 10 * time.Second})
+	})
+
+	t.Run("sliding window", func(t *testing.T) {
+		limit := RateLimit{Requests: 4, Period: 10 * time.Second, Algorithm: SlidingWindow}
+		now = time.Date(2020, 1, 1, 0, 1, 0, 0, time.UTC)
+		for i := 3; i >= 0; i-- {
+			assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: i, Reset: 10 * time.Second})
+		}
+		assert.Equal(t, allow("a", limit), RateLimitResult{Reset: 10 * time.Second, RetryAfter: 12500 * time.Millisecond})
+
+		// Half way through the next window, half of the previous window's
+		// requests are counted.
+		now = now.Add(15 * time.Second)
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 1, Reset: 5 * time.Second})
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 0, Reset: 5 * time.Second})
+		assert.Equal(t, allow("a", limit), RateLimitResult{Reset: 5 * time.Second, RetryAfter: 2500 * time.Millisecond})
+
+		// After two windows, nothing is counted.
+		now = now.Add(20 * time.Second)
+		assert.Equal(t, allow("a", limit), RateLimitResult{Allowed: true, Remaining: 3, Reset: 5 * time.Second})
+	})
+
+	t.Run("eviction", func(t *testing.T) {
+		limit := RateLimit{Requests: 1, Period: time.Second}
+		allow("c", limit)
+		assert.True(t, len(s.entries) > 0)
+		now = now.Add(time.Hour)
+		allow("c", limit)
+		assert.Equal(t, len(s.entries), 1)
+	})
+}
@@ -0,0 +1,107 @@
+package jsonrest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type failingStore struct{}
+
+var errStoreUnavailable = errors.New("store unavailable at 10.0.0.1:6379")
+
+func (failingStore) Allow(context.Context, string, jsonrest.RateLimit) (jsonrest.RateLimitResult, error) {
+	return jsonrest.RateLimitResult{}, errStoreUnavailable
+}
+
+func TestRateLimiter(t *testing.T) {
+	ok := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"ok": true}, nil
+	}
+	limit := jsonrest.RateLimit{Requests: 2, Period: time.Minute}
+
+	t.Run("by ip", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RateLimiter(limit))
+		r.Get("/", ok)
+
+		w := do(r, http.MethodGet, "/", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Result().Header.Get("RateLimit-Limit"), "2")
+		assert.Equal(t, w.Result().Header.Get("RateLimit-Remaining"), "1")
+		assert.Equal(t, w.Result().Header.Get("RateLimit-Reset"), "30")
+		assert.Equal(t, w.Result().Header.Get("RateLimit-Policy"), "2;w=60")
+
+		do(r, http.MethodGet, "/", nil, "application/json", nil)
+		w = do(r, http.MethodGet, "/", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 429)
+		assert.Equal(t, w.Result().Header.Get("RateLimit-Remaining"), "0")
+		assert.Equal(t, w.Result().Header.Get("Retry-After"), "30")
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{"code": "rate_limited", "message": "rate limit exceeded"},
+		})
+	})
+
+	t.Run("by header", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RateLimiter(jsonrest.RateLimit{Requests: 1, Period: time.Minute}, jsonrest.RateLimitByHeader("X-API-Key")))
+		r.Get("/", ok)
+
+		alice := map[string]string{"X-API-Key": "alice"}
+		bob := map[string]string{"X-API-Key": "bob"}
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", alice).Result().StatusCode, 200)
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", alice).Result().StatusCode, 429)
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", bob).Result().StatusCode, 200)
+
+		// Requests without a key are limited by IP.
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", nil).Result().StatusCode, 200)
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", nil).Result().StatusCode, 429)
+	})
+
+	t.Run("by basic auth", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RateLimiter(jsonrest.RateLimit{Requests: 1, Period: time.Minute}, jsonrest.RateLimitByBasicAuth()))
+		r.Get("/", ok)
+
+		alice := map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"}
+		bob := map[string]string{"Authorization": "Basic Ym9iOnNlY3JldA=="}
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", alice).Result().StatusCode, 200)
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", alice).Result().StatusCode, 429)
+		assert.Equal(t, do(r, http.MethodGet, "/", nil, "application/json", bob).Result().StatusCode, 200)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		var reported error
+		r := jsonrest.NewRouter(jsonrest.WithErrorHandler(func(ctx context.Context, req *jsonrest.Request, ev jsonrest.ErrorEvent) error {
+			reported = ev.Err
+			return nil
+		}))
+		r.DumpErrors = true
+		r.Use(jsonrest.RateLimiter(limit, jsonrest.RateLimitStorage(failingStore{})))
+		r.Get("/", ok)
+
+		w := do(r, http.MethodGet, "/", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 500)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{
+				"code":    "unknown_error",
+				"message": "an unknown error occurred",
+				"details": []string{"jsonrest: rate limit store failed"},
+			},
+		})
+		assert.True(t, errors.Is(reported, errStoreUnavailable))
+	})
+
+	t.Run("invalid limit", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		jsonrest.RateLimiter(jsonrest.RateLimit{})
+	})
+}
@@ -0,0 +1,112 @@
+package jsonrest
+
+import (
+	"net/http"
+	"time"
+)
+
+// ResponseInfo describes the response written for a request.
+type ResponseInfo struct {
+	// Status is the HTTP status code of the response.
+	Status int
+
+	// Header is the header of the response.
+	Header http.Header
+
+	// Bytes is the number of bytes written to the response body, before any
+	// compression.
+	Bytes int64
+
+	// EncodeDuration is the time taken to encode the response body.
+	EncodeDuration time.Duration
+
+	// ErrorCode is the code of the error rendered in the response, after any
+	// ErrorMapper or ErrorHandler, or empty if the response is not an error.
+	ErrorCode string
+}
+
+// OnResponse registers fn to be called once the response to the request has
+// been written, whether it is the endpoint's result, an error or the result of
+// a panic. Since middleware returns before the response is written, it allows
+// middleware such as loggers to observe the final status code, e.g.
+//
+//	func logging(next jsonrest.Endpoint) jsonrest.Endpoint {
+//	    return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+//	        start := time.Now()
+//	        req.OnResponse(func(res jsonrest.ResponseInfo) {
+//	            log.Printf("%s %d (%v)", req.URL().Path, res.Status, time.Since(start))
+//	        })
+//	        return next(ctx, req)
+//	    }
+//	}
+//
+// Callbacks are called in the order they were registered.
+func (r *Request) OnResponse(fn func(ResponseInfo)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onResponse = append(r.onResponse, fn)
+}
+
+// finishResponse calls the OnResponse callbacks of the request.
+func (r *Request) finishResponse() {
+	r.mu.Lock()
+	callbacks := r.onResponse
+	r.mu.Unlock()
+	if len(callbacks) == 0 {
+		return
+	}
+	info := ResponseInfo{
+		Status:         r.response.status,
+		Header:         r.response.Header(),
+		Bytes:          r.response.bytes,
+		EncodeDuration: r.response.encodeDuration,
+		ErrorCode:      r.errorCode,
+	}
+	if info.Status == 0 {
+		info.Status = http.StatusOK
+	}
+	for _, fn := range callbacks {
+		fn(info)
+	}
+}
+
+// responseRecorder is an http.ResponseWriter which records the status code and
+// size of the response it writes.
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status         int
+	bytes          int64
+	encodeDuration time.Duration
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that an
+// http.ResponseController can reach the features it supports.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Flush implements http.Flusher. It does nothing if the underlying
+// http.ResponseWriter does not support flushing.
+func (w *responseRecorder) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
@@ -0,0 +1,90 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestOnResponse(t *testing.T) {
+	var infos []jsonrest.ResponseInfo
+	r := jsonrest.NewRouter(jsonrest.WithErrorHandler(func(ctx context.Context, req *jsonrest.Request, ev jsonrest.ErrorEvent) error {
+		return nil
+	}))
+	r.Use(func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			req.OnResponse(func(info jsonrest.ResponseInfo) {
+				infos = append(infos, info)
+			})
+			return next(ctx, req)
+		}
+	})
+	r.Get("/ok", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.M{"ok": true}, nil
+	})
+	r.Get("/missing", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return nil, jsonrest.NotFound("not here")
+	})
+	r.Get("/panic", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		panic("boom")
+	})
+	r.Get("/empty", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		return jsonrest.Response{StatusCode: http.StatusNoContent, Header: http.Header{"X-Test": {"1"}}}, nil
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		code   string
+	}{
+		{"/ok", 200, ""},
+		{"/missing", 404, "not_found"},
+		{"/panic", 500, "unknown_error"},
+		{"/empty", 204, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			infos = nil
+			w := do(r, http.MethodGet, tt.path, nil, "application/json", nil)
+			assert.Equal(t, w.Result().StatusCode, tt.status)
+			assert.Equal(t, len(infos), 1)
+			assert.Equal(t, infos[0].Status, tt.status)
+			assert.Equal(t, infos[0].ErrorCode, tt.code)
+			assert.Equal(t, infos[0].Bytes, int64(w.Body.Len()))
+			assert.Equal(t, infos[0].Header.Get("Content-Type"), w.Result().Header.Get("Content-Type"))
+		})
+	}
+
+	infos = nil
+	do(r, http.MethodGet, "/empty", nil, "application/json", nil)
+	assert.Equal(t, infos[0].Header.Get("X-Test"), "1")
+	assert.Equal(t, infos[0].EncodeDuration, time.Duration(0))
+}
+
+func TestOnResponseFlush(t *testing.T) {
+	var info jsonrest.ResponseInfo
+	observe := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+			req.OnResponse(func(res jsonrest.ResponseInfo) {
+				info = res
+			})
+			return next(ctx, req)
+		}
+	}
+	h := jsonrest.HTTPMiddleware(observe)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		assert.Must(t, http.NewResponseController(w).Flush())
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, info.Status, 200)
+	assert.Equal(t, info.Bytes, int64(5))
+}
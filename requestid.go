@@ -0,0 +1,180 @@
+package jsonrest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header from which the RequestID middleware
+// reads request IDs, and on which it echoes them, by default.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the maximum length of a request ID accepted from a
+// client.
+const maxRequestIDLength = 128
+
+// A RequestIDOption configures the RequestID middleware.
+type RequestIDOption func(*requestIDConfig)
+
+// requestIDConfig is the configuration of the RequestID middleware.
+type requestIDConfig struct {
+	header   string
+	generate func() string
+	inErrors bool
+}
+
+// RequestIDHeader is a RequestIDOption which sets the header used to read and
+// echo request IDs. It defaults to DefaultRequestIDHeader.
+func RequestIDHeader(name string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.header = name
+	}
+}
+
+// RequestIDGenerator is a RequestIDOption which sets the function generating
+// IDs for requests without one, such as NewULID. It defaults to NewUUID.
+func RequestIDGenerator(generate func() string) RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.generate = generate
+	}
+}
+
+// IncludeRequestIDInErrors is a RequestIDOption which includes the request ID
+// in the body of HTTPError responses, so that reports of errors can be
+// correlated with logs.
+func IncludeRequestIDInErrors() RequestIDOption {
+	return func(c *requestIDConfig) {
+		c.inErrors = true
+	}
+}
+
+// RequestID returns a Middleware which identifies each request. The ID is read
+// from the request header, or generated if it is absent or invalid, and is
+// echoed on the response header. It is available to endpoints and later
+// middleware through Request.ID and RequestIDFromContext.
+//
+// IDs from clients are only accepted if they are at most 128 printable ASCII
+// characters, so that they are safe to log.
+func RequestID(opts ...RequestIDOption) Middleware {
+	c := requestIDConfig{header: DefaultRequestIDHeader, generate: NewUUID}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(next Endpoint) Endpoint {
+		return func(ctx context.Context, req *Request) (interface{}, error) {
+			id := req.Header(c.header)
+			if !validRequestID(id) {
+				id = c.generate()
+			}
+			req.setRequestID(id, c.inErrors)
+			req.SetResponseHeader(c.header, id)
+			return next(context.WithValue(ctx, requestIDKey{}, id), req)
+		}
+	}
+}
+
+// ID returns the ID of the request set by the RequestID middleware, or the
+// empty string if the middleware is not used.
+func (r *Request) ID() string {
+	id, _ := r.requestID()
+	return id
+}
+
+// setRequestID sets the ID of the request, and whether it is included in
+// error responses.
+func (r *Request) setRequestID(id string, inErrors bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.id, r.idInErrors = id, inErrors
+}
+
+// requestID returns the ID of the request, and whether it is included in
+// error responses.
+func (r *Request) requestID() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.id, r.idInErrors
+}
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request set by the RequestID
+// middleware, or the empty string if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether id is a non-empty string of printable ASCII
+// characters of at most maxRequestIDLength.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// NewUUID returns a random (version 4) UUID, e.g.
+// "f47ac10b-58cc-4372-a567-0e02b2c3d479".
+func NewUUID() string {
+	var b [16]byte
+	mustReadRandom(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // variant 10
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID, a lexicographically sortable identifier made of the
+// current time in milliseconds and 80 random bits, e.g.
+// "01ARZ3NDEKTSV4RRFFQ69G5FAV".
+func NewULID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], ms)
+	copy(b[:6], ts[2:])
+	mustReadRandom(b[6:])
+
+	// Encode the 128 bits as 26 characters of 5 bits, the first of which only
+	// holds 3 bits.
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+// mustReadRandom fills b with random bytes.
+func mustReadRandom(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		panic("jsonrest: cannot read random bytes: " + err.Error())
+	}
+}
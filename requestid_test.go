@@ -0,0 +1,87 @@
+package jsonrest_test
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestRequestID(t *testing.T) {
+	var ctxID string
+	endpoint := func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		ctxID = jsonrest.RequestIDFromContext(ctx)
+		if req.Query("fail") != "" {
+			return nil, jsonrest.NotFound("no such thing")
+		}
+		return jsonrest.M{"id": req.ID()}, nil
+	}
+
+	t.Run("generated", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RequestID())
+		r.Get("/", endpoint)
+
+		w := do(r, http.MethodGet, "/", nil, "application/json", nil)
+		id := w.Result().Header.Get("X-Request-ID")
+		assert.True(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(id))
+		assert.Equal(t, ctxID, id)
+		assert.JSONEqual(t, w.Body.String(), m{"id": id})
+	})
+
+	t.Run("from request", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RequestID(jsonrest.RequestIDHeader("X-Trace"), jsonrest.RequestIDGenerator(jsonrest.NewULID)))
+		r.Get("/", endpoint)
+
+		w := do(r, http.MethodGet, "/", nil, "application/json", map[string]string{"X-Trace": "abc-123"})
+		assert.Equal(t, w.Result().Header.Get("X-Trace"), "abc-123")
+		assert.JSONEqual(t, w.Body.String(), m{"id": "abc-123"})
+
+		for _, invalid := range []string{"has space", strings.Repeat("a", 129)} {
+			w = do(r, http.MethodGet, "/", nil, "application/json", map[string]string{"X-Trace": invalid})
+			assert.True(t, regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`).MatchString(w.Result().Header.Get("X-Trace")))
+		}
+	})
+
+	t.Run("in errors", func(t *testing.T) {
+		r := jsonrest.NewRouter()
+		r.Use(jsonrest.RequestID(jsonrest.IncludeRequestIDInErrors()))
+		r.Get("/", endpoint)
+
+		w := do(r, http.MethodGet, "/?fail=1", nil, "application/json", map[string]string{"X-Request-ID": "req-1"})
+		assert.Equal(t, w.Result().StatusCode, 404)
+		assert.JSONEqual(t, w.Body.String(), m{"error": m{
+			"code":       "not_found",
+			"message":    "no such thing",
+			"request_id": "req-1",
+		}})
+
+		r = jsonrest.NewRouter(jsonrest.WithProblemDetails())
+		r.Use(jsonrest.RequestID(jsonrest.IncludeRequestIDInErrors()))
+		r.Get("/", endpoint)
+
+		w = do(r, http.MethodGet, "/?fail=1", nil, "application/json", map[string]string{"X-Request-ID": "req-2"})
+		assert.JSONEqual(t, w.Body.String(), m{
+			"type":       "about:blank",
+			"title":      "Not Found",
+			"status":     404,
+			"detail":     "no such thing",
+			"instance":   "/",
+			"code":       "not_found",
+			"request_id": "req-2",
+		})
+	})
+}
+
+func TestNewULID(t *testing.T) {
+	a, b := jsonrest.NewULID(), jsonrest.NewULID()
+	assert.Equal(t, len(a), 26)
+	assert.True(t, a != b)
+	assert.True(t, a[:8] <= b[:8])
+}
@@ -0,0 +1,253 @@
+package jsonrest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// WithTimeout is an Option which bounds the time the endpoints of the router
+// and its groups, including their middleware, may take to handle a request.
+// The context passed to the endpoint is cancelled once d has elapsed, and if
+// the endpoint has not returned by then, an HTTP 503 Service Unavailable error
+// with the code "timeout" is rendered instead of its result, along with any
+// headers set with Request.SetResponseHeader, such as by the RequestID and
+// RateLimiter middleware. Anything the endpoint writes to the response
+// afterwards is discarded. Endpoints which write the response themselves may
+// stream it, and once they have started it is not replaced by the error.
+//
+// It may be given to Group or Handle to override the timeout of a group or a
+// single route, and a timeout of zero disables it, e.g.
+//
+//	r := jsonrest.NewRouter(jsonrest.WithTimeout(5 * time.Second))
+//	r.Get("/reports", reports, jsonrest.WithTimeout(time.Minute))
+//	r.Get("/events", events, jsonrest.WithTimeout(0))
+//
+// Endpoints should stop work once ctx is done, since they keep running in the
+// background until they return.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Router) {
+		r.timeout = d
+	}
+}
+
+// WithClientTimeout is an Option which lets clients shorten the timeout of a
+// request by setting the given header to their remaining time budget in
+// milliseconds, e.g. "X-Timeout-Ms: 1500". The budget is capped at max, if
+// positive, and at the timeout set with WithTimeout, if any, so clients cannot
+// extend the time allowed by the server. Invalid budgets are ignored.
+//
+// If a request exceeds a budget shorter than the server's timeout, an HTTP 504
+// Gateway Timeout error with the code "timeout" is rendered, indicating that
+// the deadline was set by the client.
+func WithClientTimeout(header string, max time.Duration) Option {
+	return func(r *Router) {
+		r.clientTimeoutHeader = header
+		r.maxClientTimeout = max
+	}
+}
+
+// requestTimeout returns the timeout of req, if any, along with the status of
+// the error rendered if it is exceeded.
+func (r *Router) requestTimeout(req *http.Request) (time.Duration, int) {
+	d, status := r.timeout, http.StatusServiceUnavailable
+	if r.clientTimeoutHeader == "" {
+		return d, status
+	}
+	ms, err := strconv.ParseInt(req.Header.Get(r.clientTimeoutHeader), 10, 64)
+	if err != nil || ms <= 0 || ms > math.MaxInt64/int64(time.Millisecond) {
+		return d, status
+	}
+	budget := time.Duration(ms) * time.Millisecond
+	if r.maxClientTimeout > 0 && budget > r.maxClientTimeout {
+		budget = r.maxClientTimeout
+	}
+	if d <= 0 || budget < d {
+		return budget, http.StatusGatewayTimeout
+	}
+	return d, status
+}
+
+// timeoutError returns the error rendered for a request which timed out.
+func timeoutError(status int) *HTTPError {
+	return Error(status, "timeout", "request timed out")
+}
+
+// endpointResult is the result of an endpoint run by callWithTimeout.
+type endpointResult struct {
+	value interface{}
+	err   error
+
+	// panic is the value of the panic raised by the endpoint, if any, and
+	// stack the stack trace at which it was raised.
+	panic interface{}
+	stack []byte
+}
+
+// callWithTimeout calls e with a context which is cancelled after d. If e
+// returns in time, its result is returned. Otherwise, the result is a timeout
+// error with the given status, unless e has already started writing the
+// response itself, and anything e writes to the response afterwards is
+// discarded.
+func callWithTimeout(ctx context.Context, d time.Duration, status int, e Endpoint, req *Request, w http.ResponseWriter) endpointResult {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	tw := &timeoutWriter{w: w, header: make(http.Header), set: make(http.Header)}
+	req.req = req.req.WithContext(ctx)
+	req.responseWriter = tw
+
+	done := make(chan endpointResult, 1)
+	go func() {
+		var res endpointResult
+		defer func() {
+			if p := recover(); p != nil {
+				res = endpointResult{panic: p, stack: debug.Stack()}
+			}
+			done <- res
+		}()
+		res.value, res.err = e(ctx, req)
+	}()
+
+	var res endpointResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		if ctx.Err() != context.DeadlineExceeded {
+			// The client went away, so there is no one to answer.
+			res = <-done
+			break
+		}
+		if !tw.timeout() {
+			// The response has already been started by the endpoint, so
+			// it cannot be replaced by the error.
+			return endpointResult{value: responseWritten{}}
+		}
+		return endpointResult{err: timeoutError(status)}
+	}
+
+	tw.finish()
+	if errors.Is(res.err, context.DeadlineExceeded) && ctx.Err() == context.DeadlineExceeded {
+		res.err = timeoutError(status)
+	}
+	return res
+}
+
+// timeoutWriter is the http.ResponseWriter of an endpoint run with a timeout.
+// Writes are passed through to the underlying writer until the timeout, after
+// which they are discarded, so that nothing is written to it concurrently
+// with the timeout error. The endpoint's header is kept separately, and only
+// copied to the underlying writer when the endpoint writes the response or
+// returns.
+type timeoutWriter struct {
+	w      http.ResponseWriter
+	header http.Header
+
+	mu sync.Mutex
+	// set holds the headers set with Request.SetResponseHeader, which are
+	// kept if the request times out.
+	set         http.Header
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Flush implements http.Flusher, so that endpoints may stream responses.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	_ = http.NewResponseController(tw.w).Flush()
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that an
+// http.ResponseController can reach the features it supports. Writes made
+// through it are not discarded after the timeout.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+// writeHeader copies the endpoint's header to the underlying writer and
+// writes the status code. It must be called with tw.mu held.
+func (tw *timeoutWriter) writeHeader(status int) {
+	copyHeader(tw.w.Header(), tw.header)
+	tw.w.WriteHeader(status)
+	tw.wroteHeader = true
+}
+
+// setHeader sets a response header on behalf of Request.SetResponseHeader.
+func (tw *timeoutWriter) setHeader(key, val string) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	tw.header.Set(key, val)
+	tw.set.Set(key, val)
+}
+
+// timeout discards any later writes, and copies the headers set with
+// Request.SetResponseHeader to the underlying writer, to be sent with the
+// timeout error. It reports false if the response has already been started.
+func (tw *timeoutWriter) timeout() bool {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader {
+		return false
+	}
+	copyHeader(tw.w.Header(), tw.set)
+	return true
+}
+
+// finish copies the endpoint's header to the underlying writer, once the
+// endpoint has returned in time, so that it is sent with the endpoint's
+// result.
+func (tw *timeoutWriter) finish() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if !tw.wroteHeader {
+		copyHeader(tw.w.Header(), tw.header)
+	}
+}
+
+// copyHeader replaces the values in dst of the headers in src.
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
@@ -0,0 +1,184 @@
+package jsonrest_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+func TestTimeout(t *testing.T) {
+	late := make(chan struct{})
+	r := jsonrest.NewRouter(jsonrest.WithTimeout(20 * time.Millisecond))
+	r.Get("/slow", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		req.SetResponseHeader("X-Late", "true")
+		close(late)
+		return jsonrest.M{"late": true}, nil
+	})
+	r.Get("/fast", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil, jsonrest.BadRequest("no deadline")
+		}
+		req.SetResponseHeader("X-Fast", "true")
+		return jsonrest.M{"fast": true}, nil
+	})
+	r.Get("/unbounded", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		_, ok := ctx.Deadline()
+		return jsonrest.M{"deadline": ok}, nil
+	}, jsonrest.WithTimeout(0))
+	r.Get("/panic", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		panic("oops")
+	})
+	r.Get("/ctx", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	t.Run("exceeded", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/slow", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 503)
+		assert.JSONEqual(t, w.Body.String(), m{
+			"error": m{"code": "timeout", "message": "request timed out"},
+		})
+		<-late
+		assert.Equal(t, w.Result().Header.Get("X-Late"), "")
+	})
+
+	t.Run("in time", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/fast", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 200)
+		assert.Equal(t, w.Result().Header.Get("X-Fast"), "true")
+		assert.JSONEqual(t, w.Body.String(), m{"fast": true})
+	})
+
+	t.Run("disabled for route", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/unbounded", nil, "application/json", nil)
+		assert.JSONEqual(t, w.Body.String(), m{"deadline": false})
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/panic", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 500)
+	})
+
+	t.Run("context error", func(t *testing.T) {
+		w := do(r, http.MethodGet, "/ctx", nil, "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 503)
+	})
+}
+
+func TestTimeoutMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	late := make(chan struct{})
+	r := jsonrest.NewRouter(jsonrest.WithTimeout(20 * time.Millisecond))
+	r.Use(
+		jsonrest.RequestID(jsonrest.IncludeRequestIDInErrors()),
+		jsonrest.AccessLog(slog.New(slog.NewJSONHandler(&buf, nil))),
+		jsonrest.RateLimiter(jsonrest.RateLimit{Requests: 10, Period: time.Minute}),
+	)
+	r.Get("/slow", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		req.SetResponseHeader("X-Late", "true")
+		req.OnResponse(func(jsonrest.ResponseInfo) {})
+		close(late)
+		return nil, nil
+	})
+
+	w := do(r, http.MethodGet, "/slow", nil, "application/json", map[string]string{"X-Request-ID": "req-1"})
+	<-late
+	assert.Equal(t, w.Result().StatusCode, 503)
+	assert.Equal(t, w.Result().Header.Get("X-Request-ID"), "req-1")
+	assert.Equal(t, w.Result().Header.Get("RateLimit-Remaining"), "9")
+	assert.Equal(t, w.Result().Header.Get("X-Late"), "")
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{"code": "timeout", "message": "request timed out", "request_id": "req-1"},
+	})
+
+	var entry m
+	assert.Must(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, entry["status"], 503.0)
+	assert.Equal(t, entry["error_code"], "timeout")
+	assert.Equal(t, entry["request_id"], "req-1")
+}
+
+func TestTimeoutStreaming(t *testing.T) {
+	flushed := make(chan struct{})
+	served := make(chan struct{})
+	done := make(chan struct{})
+	noop := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return next
+	}
+	h := jsonrest.HTTPMiddleware(noop, jsonrest.WithTimeout(20*time.Millisecond))(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		defer close(done)
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("chunk"))
+		w.(http.Flusher).Flush()
+		close(flushed)
+		<-served
+		_, err := w.Write([]byte("late"))
+		assert.Equal(t, err, http.ErrHandlerTimeout)
+	}))
+
+	w := httptest.NewRecorder()
+	go func() {
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		close(served)
+	}()
+	<-flushed
+	<-served
+	<-done
+	assert.True(t, w.Flushed)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.Equal(t, w.Result().Header.Get("Content-Type"), "text/plain")
+	assert.Equal(t, w.Body.String(), "chunk")
+}
+
+func TestClientTimeout(t *testing.T) {
+	r := jsonrest.NewRouter(
+		jsonrest.WithTimeout(time.Minute),
+		jsonrest.WithClientTimeout("X-Timeout-Ms", 20*time.Millisecond),
+	)
+	r.Get("/deadline", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		deadline, _ := ctx.Deadline()
+		if time.Until(deadline) > time.Second {
+			return jsonrest.M{"client": false}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	tests := []struct {
+		budget string
+		status int
+	}{
+		{"", 200},
+		{"invalid", 200},
+		{"-1", 200},
+		{"10", 504},
+		{"600000", 504}, // capped at 20ms
+	}
+	for _, tt := range tests {
+		t.Run(tt.budget, func(t *testing.T) {
+			w := do(r, http.MethodGet, "/deadline", nil, "application/json", map[string]string{
+				"X-Timeout-Ms": tt.budget,
+			})
+			assert.Equal(t, w.Result().StatusCode, tt.status)
+			if tt.status == 504 {
+				assert.JSONEqual(t, w.Body.String(), m{
+					"error": m{"code": "timeout", "message": "request timed out"},
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package jsonrest
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// A Tracer starts spans for the requests to the routes of routers configured
+// with WithTracer. It is typically implemented by an adapter to a tracing
+// library such as OpenTelemetry.
+type Tracer interface {
+	// StartSpan starts a server span with the given name, as a child of
+	// parent if it is valid, and returns a context containing the span. The
+	// context is passed to the endpoint, so that downstream calls made with
+	// it are correlated with the request.
+	StartSpan(ctx context.Context, name string, parent SpanContext) (context.Context, Span)
+}
+
+// A Span is a span started by a Tracer.
+type Span interface {
+	// SetAttribute annotates the span.
+	SetAttribute(key string, value interface{})
+
+	// RecordError records the internal error or panic which caused a server
+	// error.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+// WithTracer is an Option which traces the requests to the routes of the
+// router and its groups with t.
+//
+// Each span is named after the method and route pattern of the request, e.g.
+// "GET /users/:id", and is a child of the span identified by the W3C Trace
+// Context traceparent and tracestate headers of the request, if any. The span
+// is annotated with the attributes http.request.method, http.route,
+// http.response.status_code and, for errors, error.code.
+func WithTracer(t Tracer) Option {
+	return routerOption("WithTracer", func(r *Router) {
+		r.tracer = t
+	})
+}
+
+// SpanContext identifies a span, as propagated by the W3C Trace Context
+// traceparent and tracestate headers.
+type SpanContext struct {
+	TraceID [16]byte
+	SpanID  [8]byte
+
+	// Flags are the trace flags. The lowest bit is set if the trace is
+	// sampled.
+	Flags byte
+
+	// State is the vendor-specific trace state of the tracestate header.
+	State string
+}
+
+// IsValid reports whether the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// IsSampled reports whether the sampled trace flag is set.
+func (sc SpanContext) IsSampled() bool {
+	return sc.Flags&1 == 1
+}
+
+// TraceParent returns the traceparent header identifying the span, e.g.
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01".
+func (sc SpanContext) TraceParent() string {
+	var b [55]byte
+	copy(b[:], "00-")
+	hex.Encode(b[3:35], sc.TraceID[:])
+	b[35] = '-'
+	hex.Encode(b[36:52], sc.SpanID[:])
+	b[52] = '-'
+	hex.Encode(b[53:], []byte{sc.Flags})
+	return string(b[:])
+}
+
+// ParseTraceParent parses the W3C Trace Context traceparent and tracestate
+// headers. It reports false if traceparent is missing or invalid, in which
+// case tracestate is ignored.
+func ParseTraceParent(traceparent, tracestate string) (SpanContext, bool) {
+	var sc SpanContext
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || !isLowerHex(parts[0]) {
+		return sc, false
+	}
+	// Version 00 has exactly four fields, and later versions may add more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return sc, false
+	}
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 ||
+		!isLowerHex(parts[1]) || !isLowerHex(parts[2]) || !isLowerHex(parts[3]) {
+		return sc, false
+	}
+
+	var flags [1]byte
+	_, _ = hex.Decode(sc.TraceID[:], []byte(parts[1]))
+	_, _ = hex.Decode(sc.SpanID[:], []byte(parts[2]))
+	_, _ = hex.Decode(flags[:], []byte(parts[3]))
+	sc.Flags = flags[0]
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	sc.State = strings.TrimSpace(tracestate)
+	return sc, true
+}
+
+// isLowerHex reports whether s consists of lowercase hexadecimal digits.
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// observationKey is the context key of the observation of a request.
+type observationKey struct{}
+
+// observation records the response to a request for metrics and tracing. It
+// is an http.Flusher and unwraps to the underlying http.ResponseWriter, like
+// its responseRecorder.
+type observation struct {
+	responseRecorder
+
+	// err is the error rendered for the request, and code its error code.
+	err  error
+	code string
+}
+
+// observeError records the error rendered for the request with context ctx, if
+// it is being observed.
+func observeError(ctx context.Context, err error, errResponse HTTPErrorResponse) {
+	obs, ok := ctx.Value(observationKey{}).(*observation)
+	if !ok {
+		return
+	}
+	obs.err = err
+	if httpErr, ok := errResponse.(*HTTPError); ok {
+		obs.code = httpErr.Code
+	}
+}
+
+// instrument wraps handle to record metrics and trace spans for its requests,
+// if the router has metrics or a tracer.
+func (r *Router) instrument(method, route string, handle httprouter.Handle) httprouter.Handle {
+	m, tracer := r.metrics, r.tracer
+	if m == nil && tracer == nil {
+		return handle
+	}
+	labels := routeLabels{method: method, route: route}
+
+	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
+		start := time.Now()
+		ctx := req.Context()
+		if m != nil {
+			m.begin(labels)
+		}
+		var span Span
+		if tracer != nil {
+			tracestate := strings.Join(req.Header.Values("tracestate"), ",")
+			parent, _ := ParseTraceParent(req.Header.Get("traceparent"), tracestate)
+			ctx, span = tracer.StartSpan(ctx, method+" "+route, parent)
+			span.SetAttribute("http.request.method", method)
+			span.SetAttribute("http.route", route)
+		}
+
+		obs := &observation{responseRecorder: responseRecorder{ResponseWriter: w}}
+		defer func() {
+			status := obs.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if m != nil {
+				m.observe(requestLabels{labels, status, obs.code}, time.Since(start), obs.bytes)
+			}
+			if span != nil {
+				span.SetAttribute("http.response.status_code", status)
+				if obs.code != "" {
+					span.SetAttribute("error.code", obs.code)
+				}
+				if status >= 500 && obs.err != nil {
+					span.RecordError(obs.err)
+				}
+				span.End()
+			}
+		}()
+
+		ctx = context.WithValue(ctx, observationKey{}, obs)
+		handle(obs, req.WithContext(ctx), params)
+	}
+}
@@ -0,0 +1,130 @@
+package jsonrest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+type spanKey struct{}
+
+func (t *testTracer) StartSpan(ctx context.Context, name string, parent jsonrest.SpanContext) (context.Context, jsonrest.Span) {
+	span := &testSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+type testSpan struct {
+	name   string
+	parent jsonrest.SpanContext
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)                      { s.err = err }
+func (s *testSpan) End()                                       { s.ended = true }
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	r := jsonrest.NewRouter(jsonrest.WithTracer(tracer))
+	r.Get("/users/:id", func(ctx context.Context, req *jsonrest.Request) (interface{}, error) {
+		if ctx.Value(spanKey{}) == nil {
+			return nil, errors.New("no span in context")
+		}
+		switch req.Param("id") {
+		case "0":
+			return nil, jsonrest.NotFound("no such user")
+		case "fail":
+			return nil, errors.New("database unavailable")
+		}
+		return jsonrest.M{}, nil
+	})
+
+	w := do(r, http.MethodGet, "/users/1", nil, "application/json", map[string]string{
+		"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"tracestate":  "congo=t61rcWkgMzE",
+	})
+	assert.Equal(t, w.Result().StatusCode, 200)
+	span := tracer.spans[0]
+	assert.Equal(t, span.name, "GET /users/:id")
+	assert.Equal(t, span.parent.TraceParent(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	assert.Equal(t, span.parent.State, "congo=t61rcWkgMzE")
+	assert.True(t, span.parent.IsSampled())
+	assert.True(t, span.ended)
+	assert.Equal(t, span.attrs, map[string]interface{}{
+		"http.request.method":       "GET",
+		"http.route":                "/users/:id",
+		"http.response.status_code": 200,
+	})
+
+	do(r, http.MethodGet, "/users/0", nil, "application/json", map[string]string{"traceparent": "invalid"})
+	span = tracer.spans[1]
+	assert.False(t, span.parent.IsValid())
+	assert.Equal(t, span.attrs["http.response.status_code"], 404)
+	assert.Equal(t, span.attrs["error.code"], "not_found")
+	assert.Equal(t, span.err, nil)
+
+	do(r, http.MethodGet, "/users/fail", nil, "application/json", nil)
+	span = tracer.spans[2]
+	assert.Equal(t, span.attrs["http.response.status_code"], 500)
+	assert.Equal(t, span.attrs["error.code"], "unknown_error")
+	assert.Equal(t, span.err.Error(), "database unavailable")
+}
+
+func TestTracerWriter(t *testing.T) {
+	tracer := &testTracer{}
+	r := jsonrest.NewRouter(jsonrest.WithTracer(tracer), jsonrest.WithMetrics(jsonrest.NewMetrics()))
+	w := httptest.NewRecorder()
+	r.HandleHTTP(http.MethodGet, "/stream", http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, ok := rw.(http.Flusher)
+		assert.True(t, ok)
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		assert.True(t, ok)
+		assert.True(t, u.Unwrap() == http.ResponseWriter(w))
+
+		_, _ = rw.Write([]byte("chunk"))
+		assert.Must(t, http.NewResponseController(rw).Flush())
+	}))
+
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, tracer.spans[0].attrs["http.response.status_code"], 200)
+}
+
+func TestParseTraceParent(t *testing.T) {
+	tests := []struct {
+		traceparent string
+		ok          bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.traceparent, func(t *testing.T) {
+			sc, ok := jsonrest.ParseTraceParent(tt.traceparent, "a=b")
+			assert.Equal(t, ok, tt.ok)
+			if ok {
+				assert.Equal(t, sc.State, "a=b")
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package jsonrest
+
+import (
+	"context"
+	"reflect"
+)
+
+// TypedFunc is an endpoint which receives its input already bound into a value
+// of type In, and returns a value of type Out as its response body.
+type TypedFunc[In, Out any] func(ctx context.Context, req *Request, in In) (Out, error)
+
+// Typed adapts fn to an Endpoint. Before fn is called, the request body is
+// decoded into a new In as by Request.BindBody. If In is a struct, its fields
+// with "param", "query" or "header" tags are then bound with BindParams,
+// BindQuery and BindHeaders, so that a single type may describe all of the
+// endpoint's input. If the router is configured WithValidation, In is
+// validated once all of it is bound, whether or not the request has a body:
+//
+//	type GetUser struct {
+//	    ID     int    `param:"id"`
+//	    Expand string `query:"expand"`
+//	}
+//
+//	r.Get("/users/:id", jsonrest.Typed(func(ctx context.Context, req *jsonrest.Request, in GetUser) (*User, error) {
+//	    return users.Get(ctx, in.ID)
+//	}))
+//
+// The body is only bound if the request has one. The value returned by fn may
+// be a Response, as with any other endpoint. The types of fn are documented
+// by Router.OpenAPI if the route is registered with TypesOf(fn).
+func Typed[In, Out any](fn TypedFunc[In, Out]) Endpoint {
+	return func(ctx context.Context, req *Request) (interface{}, error) {
+		var in In
+		if err := bindTyped(req, &in); err != nil {
+			return nil, err
+		}
+		out, err := fn(ctx, req, in)
+		if err != nil {
+			return nil, err
+		}
+		return out, nil
+	}
+}
+
+// bindTyped binds the input of a typed endpoint into the value pointed to by
+// in. If the router is configured WithValidation, the value is validated once
+// all of it has been bound, whether or not the request has a body.
+func bindTyped(req *Request, in interface{}) error {
+	if hasBody(req.req) {
+		if err := req.decodeBody(in); err != nil {
+			return err
+		}
+	}
+	if reflect.TypeOf(in).Elem().Kind() == reflect.Struct {
+		if err := req.BindParams(in); err != nil {
+			return err
+		}
+		if err := req.BindQuery(in); err != nil {
+			return err
+		}
+		if err := req.BindHeaders(in); err != nil {
+			return err
+		}
+	}
+	if req.router != nil && req.router.validation {
+		return Validate(in)
+	}
+	return nil
+}
+
+// TypesOf is a RouteOption documenting In as the RequestType and Out as the
+// ResponseType of the route of a typed endpoint, e.g.
+//
+//	r.Put("/users/:id", jsonrest.Typed(updateUser), jsonrest.TypesOf(updateUser))
+//
+// Interface types, and an Out of Response, are not documented. RequestType
+// and ResponseType options given after it take precedence.
+func TypesOf[In, Out any](fn TypedFunc[In, Out]) RouteOption {
+	in := reflect.TypeOf((*In)(nil)).Elem()
+	out := reflect.TypeOf((*Out)(nil)).Elem()
+	return routeOptionFunc(func(rt *route) {
+		if in.Kind() != reflect.Interface {
+			rt.requestType = in
+		}
+		if out.Kind() != reflect.Interface && out != typeResponse {
+			rt.responseType = out
+		}
+	})
+}
+
+var typeResponse = reflect.TypeOf(Response{})
@@ -0,0 +1,166 @@
+package jsonrest_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type testUpdateUser struct {
+	ID     int    `json:"-" param:"id"`
+	DryRun bool   `json:"-" query:"dry_run"`
+	Name   string `json:"name" validate:"required"`
+}
+
+type testUpdateUserResult struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	DryRun bool   `json:"dry_run"`
+}
+
+func updateUser(ctx context.Context, req *jsonrest.Request, in testUpdateUser) (testUpdateUserResult, error) {
+	return testUpdateUserResult{ID: in.ID, Name: in.Name, DryRun: in.DryRun}, nil
+}
+
+func TestTyped(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithValidation())
+	r.Handle(http.MethodPut, "/users/:id", jsonrest.Typed(updateUser))
+	r.Get("/items", jsonrest.Typed(func(ctx context.Context, req *jsonrest.Request, in struct{}) ([]string, error) {
+		return []string{"a", "b"}, nil
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		want   interface{}
+	}{
+		{
+			name:   "binds body, params and query",
+			method: http.MethodPut,
+			path:   "/users/7?dry_run=true",
+			body:   `{"name": "alice"}`,
+			status: 200,
+			want:   m{"id": 7, "name": "alice", "dry_run": true},
+		},
+		{
+			name:   "validates body",
+			method: http.MethodPut,
+			path:   "/users/7",
+			body:   `{"name": ""}`,
+			status: 422,
+			want: m{"error": m{
+				"code":    "unprocessable_entity",
+				"message": "validation failed",
+				"fields":  []m{{"pointer": "/name", "message": "is required"}},
+			}},
+		},
+		{
+			name:   "invalid param",
+			method: http.MethodPut,
+			path:   "/users/abc",
+			body:   `{"name": "alice"}`,
+			status: 400,
+			want: m{"error": m{
+				"code":    "bad_request",
+				"message": "invalid url parameters",
+				"details": []string{`id: cannot parse "abc" as integer`},
+			}},
+		},
+		{
+			name:   "no body",
+			method: http.MethodGet,
+			path:   "/items",
+			status: 200,
+			want:   []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(r, tt.method, tt.path, strings.NewReader(tt.body), "application/json", nil)
+			assert.Equal(t, w.Result().StatusCode, tt.status)
+			assert.JSONEqual(t, w.Body.String(), tt.want)
+		})
+	}
+}
+
+type testCreateUser struct {
+	ID   int    `param:"id" validate:"required"`
+	Q    string `query:"q" validate:"required"`
+	Name string `json:"name" validate:"required"`
+}
+
+func TestTypedValidation(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithValidation())
+	endpoint := jsonrest.Typed(func(ctx context.Context, req *jsonrest.Request, in testCreateUser) (testCreateUser, error) {
+		return in, nil
+	})
+	r.Post("/users/:id", endpoint)
+	r.Get("/users/:id", endpoint)
+
+	w := do(r, http.MethodPost, "/users/5?q=x", strings.NewReader(`{"name": "bob"}`), "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 200)
+	assert.JSONEqual(t, w.Body.String(), m{"ID": 5, "Q": "x", "name": "bob"})
+
+	w = do(r, http.MethodGet, "/users/5", nil, "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 422)
+	assert.JSONEqual(t, w.Body.String(), m{"error": m{
+		"code":    "unprocessable_entity",
+		"message": "validation failed",
+		"fields": []m{
+			{"pointer": "/Q", "message": "is required"},
+			{"pointer": "/name", "message": "is required"},
+		},
+	}})
+}
+
+func TestTypedOpenAPI(t *testing.T) {
+	auth := func(next jsonrest.Endpoint) jsonrest.Endpoint {
+		return next
+	}
+	r := jsonrest.NewRouter()
+	r.Handle(http.MethodPut, "/users/:id", auth(jsonrest.Typed(updateUser)), jsonrest.TypesOf(updateUser))
+	r.Post("/users", jsonrest.Typed(updateUser), jsonrest.TypesOf(updateUser), jsonrest.ResponseType(http.StatusCreated, testUser{}))
+	r.Routes(jsonrest.RouteMap{
+		"PATCH /users/:id": jsonrest.Route(jsonrest.Typed(updateUser), jsonrest.TypesOf(updateUser)),
+	})
+
+	doc, err := json.Marshal(r.OpenAPI())
+	assert.Must(t, err)
+	var got struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name string `json:"name"`
+			} `json:"parameters"`
+			RequestBody *struct{} `json:"requestBody"`
+			Responses   map[string]struct {
+				Content map[string]struct {
+					Schema struct {
+						Ref string `json:"$ref"`
+					} `json:"schema"`
+				} `json:"content"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	assert.Must(t, json.Unmarshal(doc, &got))
+
+	put := got.Paths["/users/{id}"]["put"]
+	assert.Equal(t, len(put.Parameters), 2)
+	assert.True(t, put.RequestBody != nil)
+	assert.Equal(t, put.Responses["200"].Content["application/json"].Schema.Ref, "#/components/schemas/testUpdateUserResult")
+
+	patch := got.Paths["/users/{id}"]["patch"]
+	assert.True(t, patch.RequestBody != nil)
+	assert.Equal(t, patch.Responses["200"].Content["application/json"].Schema.Ref, "#/components/schemas/testUpdateUserResult")
+
+	post := got.Paths["/users"]["post"]
+	assert.Equal(t, post.Responses["201"].Content["application/json"].Schema.Ref, "#/components/schemas/testUser")
+}
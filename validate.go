@@ -0,0 +1,377 @@
+package jsonrest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// A Validator is a value that can validate itself. Validate reports the
+// value's validation errors, if any; returning an HTTPErrorResponse renders it
+// as-is.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes why the value at a location in the request body is
+// invalid.
+type FieldError struct {
+	// Pointer is a JSON pointer (RFC 6901) to the invalid value, e.g.
+	// "/items/0/name".
+	Pointer string `json:"pointer"`
+
+	// Message describes why the value is invalid.
+	Message string `json:"message"`
+}
+
+// Validate checks v against the rules in its "validate" struct tags, and calls
+// the Validate method of any Validator it contains. Rules are separated by
+// commas:
+//
+//	required     the value must not be the zero value
+//	min=N        numbers must be at least N; strings, slices and maps must
+//	             have a length of at least N
+//	max=N        as min, but at most N
+//	enum=a|b|c   the value must be one of the given values
+//	regex=EXPR   strings must match the regular expression EXPR, which may
+//	             not contain commas
+//
+// For example:
+//
+//	type CreateUser struct {
+//	    Name  string   `json:"name" validate:"required,max=100"`
+//	    Role  string   `json:"role" validate:"enum=admin|member"`
+//	    Email string   `json:"email" validate:"regex=^[^@]+@[^@]+$"`
+//	    Tags  []string `json:"tags" validate:"max=10"`
+//	}
+//
+// If v is invalid, an HTTP 422 Unprocessable Entity error is returned listing
+// each invalid field by its JSON pointer. If a tag is invalid, an internal
+// error is returned; see CheckValidation.
+func Validate(v interface{}) error {
+	var fields []FieldError
+	if err := validateValue(reflect.ValueOf(v), "", &fields); err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		err := UnprocessableEntity("validation failed")
+		err.Fields = fields
+		return err
+	}
+	return nil
+}
+
+// validateValue validates v, located at the JSON pointer path, appending any
+// field errors to fields. An error is returned if the top-level Validator
+// returns an HTTPErrorResponse, or if a "validate" tag is invalid.
+func validateValue(v reflect.Value, path string, fields *[]FieldError) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		rules, err := structRules(t)
+		if err != nil {
+			return err
+		}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			fieldPath := path
+			if !field.Anonymous || name != field.Name {
+				fieldPath = path + "/" + escapePointer(name)
+			}
+			fv := v.Field(i)
+			if msg := checkRules(fv, rules[i]); msg != "" {
+				*fields = append(*fields, FieldError{Pointer: fieldPath, Message: msg})
+				continue
+			}
+			if err := validateValue(fv, fieldPath, fields); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), path+"/"+strconv.Itoa(i), fields); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() == reflect.String {
+			iter := v.MapRange()
+			for iter.Next() {
+				if err := validateValue(iter.Value(), path+"/"+escapePointer(iter.Key().String()), fields); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return callValidator(v, path, fields)
+}
+
+// callValidator calls the Validate method of v, if it implements Validator.
+// If only a pointer to v does and v is not addressable, the method is called
+// on a pointer to a copy of v.
+func callValidator(v reflect.Value, path string, fields *[]FieldError) error {
+	var validator Validator
+	switch {
+	case v.CanAddr() && v.Addr().Type().Implements(typeValidator):
+		validator = v.Addr().Interface().(Validator)
+	case v.CanInterface() && v.Type().Implements(typeValidator):
+		validator = v.Interface().(Validator)
+	case v.CanInterface() && reflect.PtrTo(v.Type()).Implements(typeValidator):
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		validator = p.Interface().(Validator)
+	default:
+		return nil
+	}
+
+	err := validator.Validate()
+	if err == nil {
+		return nil
+	}
+	if errResponse, ok := err.(HTTPErrorResponse); ok && path == "" {
+		return errResponse
+	}
+	*fields = append(*fields, FieldError{Pointer: path, Message: err.Error()})
+	return nil
+}
+
+var typeValidator = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// CheckValidation reports whether the "validate" tags of t, and of the types
+// it contains, are valid. Routes registered with a request type, such as Typed
+// endpoints, are checked when they are registered with a router configured
+// WithValidation, so that invalid tags are found at startup rather than on the
+// first request.
+func CheckValidation(t reflect.Type) error {
+	return checkValidation(t, make(map[reflect.Type]bool))
+}
+
+func checkValidation(t reflect.Type, seen map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, err := structRules(t); err != nil {
+			return err
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if err := checkValidation(t.Field(i).Type, seen); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return checkValidation(t.Elem(), seen)
+	}
+	return nil
+}
+
+// rule is a parsed validation rule.
+type rule struct {
+	name string
+	arg  string
+
+	bound  float64        // min and max
+	values []string       // enum
+	re     *regexp.Regexp // regex
+}
+
+// parsedRules holds the rules of the fields of a struct type, or the error
+// parsing them.
+type parsedRules struct {
+	fields [][]rule
+	err    error
+}
+
+// rulesCache holds the parsedRules of struct types.
+var rulesCache sync.Map
+
+// structRules returns the rules of each field of the struct type t, indexed by
+// field. The rules are parsed once per type.
+func structRules(t reflect.Type) ([][]rule, error) {
+	if cached, ok := rulesCache.Load(t); ok {
+		pr := cached.(*parsedRules)
+		return pr.fields, pr.err
+	}
+
+	pr := &parsedRules{fields: make([][]rule, t.NumField())}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		rules, err := parseRules(field.Tag.Get("validate"))
+		if err != nil {
+			pr.fields, pr.err = nil, fmt.Errorf("jsonrest: invalid validate tag of %v.%s: %v", t, field.Name, err)
+			break
+		}
+		pr.fields[i] = rules
+	}
+	rulesCache.Store(t, pr)
+	return pr.fields, pr.err
+}
+
+// parseRules parses the comma-separated rules of a "validate" tag.
+func parseRules(tag string) ([]rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	var rules []rule
+	for _, s := range strings.Split(tag, ",") {
+		r := rule{name: s}
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			r.name, r.arg = s[:i], s[i+1:]
+		}
+
+		var err error
+		switch r.name {
+		case "required":
+		case "min", "max":
+			r.bound, err = strconv.ParseFloat(r.arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule %q", r.name, r.arg)
+			}
+		case "enum":
+			r.values = strings.Split(r.arg, "|")
+		case "regex":
+			r.re, err = regexp.Compile(r.arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regex rule: %v", err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown validation rule %q", s)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// checkRules checks v against the rules, returning a message describing the
+// first rule that is not satisfied.
+func checkRules(v reflect.Value, rules []rule) string {
+	for _, r := range rules {
+		if r.name == "required" {
+			if v.IsZero() {
+				return "is required"
+			}
+			continue
+		}
+
+		// Other rules apply to the value of optional fields, if present.
+		ev := v
+		for ev.Kind() == reflect.Ptr || ev.Kind() == reflect.Interface {
+			if ev.IsNil() {
+				return ""
+			}
+			ev = ev.Elem()
+		}
+
+		var msg string
+		switch r.name {
+		case "min", "max":
+			msg = checkBound(ev, r)
+		case "enum":
+			msg = checkEnum(ev, r.values)
+		case "regex":
+			msg = checkRegex(ev, r)
+		}
+		if msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// checkBound checks the min or max rule.
+func checkBound(v reflect.Value, r rule) string {
+	var (
+		n       float64
+		subject = "must be"
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, subject = float64(utf8.RuneCountInString(v.String())), "length must be"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, subject = float64(v.Len()), "length must be"
+	default:
+		return ""
+	}
+
+	if r.name == "min" && n < r.bound {
+		return subject + " at least " + r.arg
+	}
+	if r.name == "max" && n > r.bound {
+		return subject + " at most " + r.arg
+	}
+	return ""
+}
+
+// checkEnum checks the enum rule.
+func checkEnum(v reflect.Value, values []string) string {
+	s := fmt.Sprint(v.Interface())
+	for _, allowed := range values {
+		if s == allowed {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(values, ", ")
+}
+
+// checkRegex checks the regex rule.
+func checkRegex(v reflect.Value, r rule) string {
+	if v.Kind() != reflect.String {
+		return ""
+	}
+	if !r.re.MatchString(v.String()) {
+		return "must match " + r.arg
+	}
+	return ""
+}
+
+// jsonFieldName returns the name of the struct field in its JSON encoding.
+func jsonFieldName(field reflect.StructField) string {
+	tag := field.Tag.Get("json")
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		tag = tag[:i]
+	}
+	if tag == "" {
+		return field.Name
+	}
+	return tag
+}
+
+// escapePointer escapes a JSON pointer reference token.
+func escapePointer(s string) string {
+	s = strings.Replace(s, "~", "~0", -1)
+	return strings.Replace(s, "/", "~1", -1)
+}
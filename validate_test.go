@@ -0,0 +1,157 @@
+package jsonrest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deliveroo/assert-go"
+
+	"github.com/deliveroo/jsonrest-go"
+)
+
+type testItem struct {
+	Name     string `json:"name" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type testOrder struct {
+	ID     string     `json:"id" validate:"required,regex=^o_[0-9]+$"`
+	Status string     `json:"status" validate:"enum=open|closed"`
+	Note   *string    `json:"note" validate:"max=5"`
+	Items  []testItem `json:"items" validate:"min=1"`
+	Coupon string     `json:"coupon"`
+}
+
+func (o *testOrder) Validate() error {
+	if o.Coupon == "expired" {
+		return errors.New("coupon has expired")
+	}
+	return nil
+}
+
+func TestValidate(t *testing.T) {
+	note := "too long"
+	tests := []struct {
+		name  string
+		order testOrder
+		want  []jsonrest.FieldError
+	}{
+		{
+			name:  "valid",
+			order: testOrder{ID: "o_1", Status: "open", Items: []testItem{{"apple", 1}}},
+		},
+		{
+			name:  "rules",
+			order: testOrder{ID: "1", Status: "pending", Note: &note},
+			want: []jsonrest.FieldError{
+				{Pointer: "/id", Message: "must match ^o_[0-9]+$"},
+				{Pointer: "/status", Message: "must be one of open, closed"},
+				{Pointer: "/note", Message: "length must be at most 5"},
+				{Pointer: "/items", Message: "length must be at least 1"},
+			},
+		},
+		{
+			name:  "nested",
+			order: testOrder{ID: "o_1", Status: "open", Items: []testItem{{"apple", 1}, {"", 11}}},
+			want: []jsonrest.FieldError{
+				{Pointer: "/items/1/name", Message: "is required"},
+				{Pointer: "/items/1/quantity", Message: "must be at most 10"},
+			},
+		},
+		{
+			name:  "validator",
+			order: testOrder{ID: "o_1", Status: "open", Items: []testItem{{"apple", 1}}, Coupon: "expired"},
+			want: []jsonrest.FieldError{
+				{Pointer: "", Message: "coupon has expired"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := jsonrest.Validate(&tt.order)
+			if tt.want == nil {
+				assert.Must(t, err)
+				return
+			}
+			httpErr, ok := err.(*jsonrest.HTTPError)
+			assert.True(t, ok)
+			assert.Equal(t, httpErr.Status, 422)
+			assert.Equal(t, httpErr.Fields, tt.want)
+		})
+	}
+}
+
+func TestBindBodyValidation(t *testing.T) {
+	r := jsonrest.NewRouter(jsonrest.WithValidation())
+	r.Post("/orders", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+		var order testOrder
+		if err := r.BindBody(&order); err != nil {
+			return nil, err
+		}
+		return order, nil
+	})
+
+	w := do(r, http.MethodPost, "/orders", strings.NewReader(`{"id": "o_1", "status": "open", "items": [{"quantity": 1}]}`), "application/json", nil)
+	assert.Equal(t, w.Result().StatusCode, 422)
+	assert.JSONEqual(t, w.Body.String(), m{
+		"error": m{
+			"code":    "unprocessable_entity",
+			"message": "validation failed",
+			"fields": []m{
+				{"pointer": "/items/0/name", "message": "is required"},
+			},
+		},
+	})
+}
+
+func TestValidateNonAddressable(t *testing.T) {
+	order := testOrder{ID: "o_1", Status: "open", Items: []testItem{{"apple", 1}}, Coupon: "expired"}
+	err := jsonrest.Validate(order)
+	httpErr, ok := err.(*jsonrest.HTTPError)
+	assert.True(t, ok)
+	assert.Equal(t, httpErr.Fields, []jsonrest.FieldError{{Pointer: "", Message: "coupon has expired"}})
+}
+
+func TestInvalidValidationTags(t *testing.T) {
+	type unknownRule struct {
+		Name string `json:"name" validate:"requird"`
+	}
+	type badBound struct {
+		Items []unknownRule `json:"items"`
+		Count int           `json:"count" validate:"max=ten"`
+	}
+	type badRegex struct {
+		Name string `json:"name" validate:"regex=^(?=a)"`
+	}
+
+	assert.Must(t, jsonrest.CheckValidation(reflect.TypeOf(testOrder{})))
+	for _, v := range []interface{}{unknownRule{}, &badBound{}, []badRegex{}} {
+		assert.True(t, jsonrest.CheckValidation(reflect.TypeOf(v)) != nil)
+	}
+	assert.True(t, jsonrest.Validate(badBound{Count: 1}) != nil)
+
+	t.Run("at request", func(t *testing.T) {
+		r := jsonrest.NewRouter(jsonrest.WithValidation())
+		r.Post("/", func(ctx context.Context, r *jsonrest.Request) (interface{}, error) {
+			var v unknownRule
+			return nil, r.BindBody(&v)
+		})
+		w := do(r, http.MethodPost, "/", strings.NewReader(`{"name": "a"}`), "application/json", nil)
+		assert.Equal(t, w.Result().StatusCode, 500)
+	})
+
+	t.Run("at registration", func(t *testing.T) {
+		defer func() {
+			assert.True(t, recover() != nil)
+		}()
+		r := jsonrest.NewRouter(jsonrest.WithValidation())
+		create := func(ctx context.Context, r *jsonrest.Request, in badRegex) (interface{}, error) {
+			return nil, nil
+		}
+		r.Post("/", jsonrest.Typed(create), jsonrest.TypesOf(create))
+	})
+}